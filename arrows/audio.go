@@ -4,12 +4,16 @@ package main
 
 import (
 	"bytes"
+	"fmt"
 	"log"
+	"os"
+	"path/filepath"
 	"time"
 
 	_ "embed"
 
 	"github.com/faiface/beep"
+	"github.com/faiface/beep/effects"
 	"github.com/faiface/beep/speaker"
 	"github.com/faiface/beep/wav"
 )
@@ -29,6 +33,15 @@ var (
 
 	audioBuffer *beep.Buffer
 	audioLimits [5]int
+
+	audioMixer  *beep.Mixer
+	audioVolume *effects.Volume
+	audioSynth  bool // use generated sounds
+)
+
+const (
+	minVolume = -6
+	maxVolume = 2
 )
 
 func reverse(s beep.Streamer, n int) beep.Streamer {
@@ -50,50 +63,132 @@ func reverse(s beep.Streamer, n int) beep.Streamer {
 	})
 }
 
-func audioInit() {
-	audioRemove, format, err := wav.Decode(bytes.NewBuffer(wavRemove))
-	if err != nil {
-		log.Fatalf("%+v", err)
-	}
-	defer audioRemove.Close()
+// load a sound effect from the sounds directory (if present), or from the embedded defaults
+func loadSound(dir, name string, data []byte) (beep.StreamSeekCloser, beep.Format) {
+	if dir != "" {
+		path := filepath.Join(dir, name+".wav")
 
-	audioMove, _, err := wav.Decode(bytes.NewBuffer(wavMove))
-	if err != nil {
-		log.Fatalf("%+v", err)
+		if f, err := os.Open(path); err == nil {
+			s, format, err := wav.Decode(f)
+			if err == nil {
+				return s, format
+			}
+
+			log.Printf("cannot load %v: %v", path, err)
+			f.Close()
+		}
 	}
-	defer audioMove.Close()
 
-	audioStop, _, err := wav.Decode(bytes.NewBuffer(wavStop))
-	if err != nil {
-		log.Fatalf("%+v", err)
+	if data == nil {
+		return nil, beep.Format{}
 	}
-	defer audioStop.Close()
 
-	audioShuffle, _, err := wav.Decode(bytes.NewBuffer(wavShuffle))
+	s, format, err := wav.Decode(bytes.NewBuffer(data))
 	if err != nil {
 		log.Fatalf("%+v", err)
 	}
-	defer audioShuffle.Close()
+
+	return s, format
+}
+
+// initialize audio, loading the sound effects from dir
+// (remove.wav, move.wav, stop.wav, shuffle.wav and undo.wav)
+// or using the embedded ones.
+// If synth is true, the sound effects are generated (and change with the current sequence)
+func audioInit(dir string, synth bool) {
+	audioSynth = synth
+
+	audioRemove, format := loadSound(dir, "remove", wavRemove)
+	defer audioRemove.Close()
 
 	speaker.Init(format.SampleRate, format.SampleRate.N(time.Second/10))
 
 	audioBuffer = beep.NewBuffer(format)
 
+	// all effects are resampled to the sample rate of the first one
+	appendSound := func(name string, data []byte) bool {
+		s, f := loadSound(dir, name, data)
+		if s == nil {
+			return false
+		}
+
+		defer s.Close()
+
+		if f.SampleRate != format.SampleRate {
+			audioBuffer.Append(beep.Resample(4, f.SampleRate, format.SampleRate, s))
+		} else {
+			audioBuffer.Append(s)
+		}
+
+		return true
+	}
+
 	audioBuffer.Append(audioRemove)
 	audioLimits[0] = audioBuffer.Len() // 0 to audioLimits[0]
 
-	audioBuffer.Append(audioMove)
+	appendSound("move", wavMove)
 	audioLimits[1] = audioBuffer.Len() // audioLimits[0] to audioLimits[1]
 
-	audioBuffer.Append(audioStop)
+	appendSound("stop", wavStop)
 	audioLimits[2] = audioBuffer.Len() // audioLimits[1] to audioLimits[2]
 
-	audioBuffer.Append(audioShuffle)
+	appendSound("shuffle", wavShuffle)
 	audioLimits[3] = audioBuffer.Len() // audioLimits[2] to audioLimits[3]
 
-	s := audioBuffer.Streamer(0, audioLimits[0])
-	audioBuffer.Append(reverse(s, audioLimits[0]))
+	if !appendSound("undo", nil) { // default: reversed "remove" sound
+		s := audioBuffer.Streamer(0, audioLimits[0])
+		audioBuffer.Append(reverse(s, audioLimits[0]))
+	}
 	audioLimits[4] = audioBuffer.Len() // audioLimits[3] to audioLimits[4]
+
+	// all sounds are played through a mixer, with volume control
+	audioMixer = &beep.Mixer{}
+	audioVolume = &effects.Volume{Streamer: audioMixer, Base: 2}
+	speaker.Play(audioVolume)
+}
+
+// mute/unmute audio
+func audioMute() {
+	if audioVolume == nil {
+		return
+	}
+
+	speaker.Lock()
+	audioVolume.Silent = !audioVolume.Silent
+	speaker.Unlock()
+}
+
+// change volume (delta is in "steps", each step doubles or halves the volume)
+func audioChangeVolume(delta float64) {
+	if audioVolume == nil {
+		return
+	}
+
+	speaker.Lock()
+	audioVolume.Volume += delta
+	if audioVolume.Volume < minVolume {
+		audioVolume.Volume = minVolume
+	} else if audioVolume.Volume > maxVolume {
+		audioVolume.Volume = maxVolume
+	}
+	audioVolume.Silent = false
+	speaker.Unlock()
+}
+
+// return a description of the audio status
+func audioStatus() string {
+	if audioVolume == nil {
+		return "audio disabled"
+	}
+
+	speaker.Lock()
+	defer speaker.Unlock()
+
+	if audioVolume.Silent {
+		return "audio muted"
+	}
+
+	return fmt.Sprintf("volume %+.1f", audioVolume.Volume)
 }
 
 func audioPlay(mov Updates) {
@@ -101,8 +196,25 @@ func audioPlay(mov Updates) {
 		return
 	}
 
-	var s beep.StreamSeeker
+	var s beep.Streamer
+
+	if audioSynth {
+		s = synthSound(audioBuffer.Format().SampleRate, mov, game.Seq)
+	} else {
+		s = audioEffect(mov)
+	}
+
+	if s == nil {
+		return
+	}
+
+	speaker.Lock()
+	audioMixer.Add(s)
+	speaker.Unlock()
+}
 
+// return the sound effect for the update (from the default or loaded sounds)
+func audioEffect(mov Updates) (s beep.Streamer) {
 	switch mov {
 	case Remove:
 		s = audioBuffer.Streamer(0, audioLimits[0])
@@ -120,5 +232,5 @@ func audioPlay(mov Updates) {
 		s = audioBuffer.Streamer(audioLimits[3], audioLimits[4])
 	}
 
-	speaker.Play(s)
+	return
 }
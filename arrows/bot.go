@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bot protocol
+//
+// A bot is a program that plays a game talking on stdin/stdout, one command per line
+// (a new instance of the bot is started for each game). Lines from the bot that start with "#" are ignored,
+// and the bot stderr is passed through (for debugging).
+//
+//	game -> bot:  board <width> <height> <remain> <shuffles> <score>
+//	              followed by <height> lines of <width> characters (^ v < > for the arrows, . for the empty cells)
+//	game -> bot:  go                      the bot should reply with a command (within the time limit)
+//	bot -> game:  move <x> <y>            move/remove the arrow at x,y (0-based, from the top-left cell)
+//	bot -> game:  shuffle                 shuffle the arrows (the bot loses the game if no shuffles are left, with -shuffles)
+//	bot -> game:  resign                  give up the game
+//	game -> bot:  result <result>         the result of a move: remove, move, none or invalid
+//	game -> bot:  gameover <score>        the game is over, the bot should exit
+//
+// A bot that doesn't reply in time, sends an invalid command or exits before the end of the game
+// loses the game (with a score of 0).
+
+// the bots playing ("-bot" can be repeated)
+type BotList []string
+
+func (b *BotList) String() string {
+	return strings.Join(*b, ",")
+}
+
+func (b *BotList) Set(v string) error {
+	*b = append(*b, v)
+	return nil
+}
+
+// results for a bot on a board
+type BotResult struct {
+	Bot      string  `json:"bot"`
+	Board    int     `json:"board"`
+	Seed     int64   `json:"seed"`
+	Solved   bool    `json:"solved"`
+	Shuffles int     `json:"shuffles"`
+	Moves    int     `json:"moves"`
+	MaxSeq   int     `json:"maxseq"`
+	Score    int     `json:"score"`
+	Time     float64 `json:"time"` // seconds spent playing
+	Error    string  `json:"error,omitempty"`
+}
+
+// results for a bot on all boards
+type BotSummary struct {
+	Bot      string  `json:"bot"`
+	Games    int     `json:"games"`
+	Solved   int     `json:"solved"`
+	Errors   int     `json:"errors"`
+	Score    int     `json:"score"` // total score
+	ScoreAvg float64 `json:"score_avg"`
+	Time     float64 `json:"time"`
+}
+
+// a running bot
+type botProcess struct {
+	cmd   *exec.Cmd
+	in    *bufio.Writer
+	lines chan string
+	done  chan struct{}
+}
+
+func startBot(command string) (*botProcess, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty bot command")
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	b := &botProcess{cmd: cmd, in: bufio.NewWriter(stdin), lines: make(chan string), done: make(chan struct{})}
+
+	go func() {
+		defer close(b.lines)
+
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			if l := strings.TrimSpace(scanner.Text()); l != "" && !strings.HasPrefix(l, "#") {
+				select {
+				case b.lines <- l:
+				case <-b.done: // the game is over
+					return
+				}
+			}
+		}
+	}()
+
+	return b, nil
+}
+
+func (b *botProcess) send(format string, args ...interface{}) {
+	fmt.Fprintf(b.in, format+"\n", args...)
+}
+
+// send the board and ask for a command
+func (b *botProcess) sendBoard(g *Game) error {
+	b.send("board %v %v %v %v %v", g.Width-2, g.Height-2, g.Count, g.Shuffles, g.Score)
+
+	for _, row := range g.Screen[1 : g.Height-1] {
+		line := make([]byte, 0, g.Width-2)
+		for _, col := range row[1 : g.Width-1] {
+			line = append(line, gridChars[col])
+		}
+
+		b.send("%s", line)
+	}
+
+	b.send("go")
+	return b.in.Flush()
+}
+
+// wait for the next command from the bot
+func (b *botProcess) command(timeout time.Duration) (string, error) {
+	select {
+	case l, ok := <-b.lines:
+		if !ok {
+			return "", fmt.Errorf("bot exited")
+		}
+
+		return l, nil
+
+	case <-time.After(timeout):
+		return "", fmt.Errorf("timeout")
+	}
+}
+
+// tell the bot the game is over and wait for it to exit (or kill it)
+func (b *botProcess) stop(score int) {
+	close(b.done)
+
+	b.send("gameover %v", score)
+	b.in.Flush()
+
+	done := make(chan error, 1)
+	go func() { done <- b.cmd.Wait() }()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		b.cmd.Process.Kill()
+		<-done
+	}
+}
+
+// let the bot play a board
+func playBot(bot string, board int, seed int64, moveTime time.Duration) (res BotResult) {
+	var g Game
+
+	g.SetupSeed(gameWidth, gameHeight, 1, 1, seed)
+	res = BotResult{Bot: bot, Board: board, Seed: g.Seed}
+
+	b, err := startBot(bot)
+	if err != nil {
+		res.Error = err.Error()
+		return
+	}
+
+	// limit the number of commands, in case the bot gets stuck
+	maxCommands := 10*g.Count + maxShuffles
+	start := time.Now()
+
+	for n := 0; g.Count > 0 && err == nil; n++ {
+		if n == maxCommands {
+			err = fmt.Errorf("too many commands")
+			break
+		}
+
+		if err = b.sendBoard(&g); err != nil {
+			break
+		}
+
+		var cmd string
+		if cmd, err = b.command(moveTime); err != nil {
+			break
+		}
+
+		args := strings.Fields(cmd)
+
+		switch args[0] {
+		case "move":
+			var x, y int
+
+			if len(args) != 3 {
+				err = fmt.Errorf("invalid command %q", cmd)
+				break
+			}
+
+			if x, err = strconv.Atoi(args[1]); err != nil {
+				break
+			}
+
+			if y, err = strconv.Atoi(args[2]); err != nil {
+				break
+			}
+
+			mov := Invalid
+			if _, _, dir := g.Peek(x+1, y+1); dir != InvalidDir && dir != Empty {
+				_, _, mov = g.Update(x+1, y+1, Move)
+			}
+
+			b.send("result %v", updateNames[mov])
+
+		case "shuffle":
+			if g.Shuffles == maxShuffles {
+				err = fmt.Errorf("too many shuffles")
+				break
+			}
+
+			// the same rules of the UI: the shuffles are limited by -shuffles, and cost points
+			if !g.PlayShuffle(shuffleMode) {
+				err = fmt.Errorf("no shuffles left")
+			}
+
+		case "resign":
+			res.Time = time.Since(start).Seconds()
+			res.Shuffles, res.Moves, res.MaxSeq = g.Shuffles, g.Moves, g.MaxSeq
+			res.Score = g.ComputeScore()
+			b.stop(res.Score)
+			return
+
+		default:
+			err = fmt.Errorf("invalid command %q", cmd)
+		}
+	}
+
+	res.Time = time.Since(start).Seconds()
+	res.Shuffles, res.Moves, res.MaxSeq = g.Shuffles, g.Moves, g.MaxSeq
+
+	if err != nil {
+		res.Error = err.Error()
+		b.stop(0)
+		return
+	}
+
+	res.Solved = true
+	res.Score = g.ComputeScore()
+	b.stop(res.Score)
+	return
+}
+
+func summarizeBots(bots []string, results []BotResult) []BotSummary {
+	sums := map[string]*BotSummary{}
+
+	for _, bot := range bots {
+		sums[bot] = &BotSummary{Bot: bot}
+	}
+
+	for _, r := range results {
+		sum := sums[r.Bot]
+		sum.Games++
+		sum.Score += r.Score
+		sum.Time += r.Time
+
+		if r.Solved {
+			sum.Solved++
+		}
+		if r.Error != "" {
+			sum.Errors++
+		}
+	}
+
+	var list []BotSummary
+
+	for _, bot := range bots {
+		sum := sums[bot]
+		if sum.Games > 0 {
+			sum.ScoreAvg = float64(sum.Score) / float64(sum.Games)
+		}
+
+		list = append(list, *sum)
+	}
+
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Score > list[j].Score })
+	return list
+}
+
+// let all the bots play the same n boards, without UI,
+// and write the results as "csv" or "json"
+func runTournament(bots []string, n int, moveTime time.Duration, format string, w io.Writer) error {
+	var results []BotResult
+
+	// the board seeds are generated from the game seed, so that a tournament can be repeated
+	seed := gameSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+
+	for i := 0; i < n; i++ {
+		seed := rng.Int63()
+
+		for _, bot := range bots {
+			results = append(results, playBot(bot, i+1, seed, moveTime))
+		}
+	}
+
+	sums := summarizeBots(bots, results)
+
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			Summary []BotSummary `json:"summary"`
+			Results []BotResult  `json:"results"`
+		}{sums, results})
+
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"bot", "board", "seed", "solved", "shuffles", "moves", "maxseq", "score", "time", "error"})
+
+		for _, r := range results {
+			cw.Write([]string{
+				r.Bot,
+				strconv.Itoa(r.Board),
+				strconv.FormatInt(r.Seed, 10),
+				strconv.FormatBool(r.Solved),
+				strconv.Itoa(r.Shuffles),
+				strconv.Itoa(r.Moves),
+				strconv.Itoa(r.MaxSeq),
+				strconv.Itoa(r.Score),
+				strconv.FormatFloat(r.Time, 'f', 3, 64),
+				r.Error,
+			})
+		}
+
+		cw.Flush()
+
+		// the ranking goes to stderr, so that stdout is a valid csv file
+		for i, s := range sums {
+			fmt.Fprintf(os.Stderr, "%2d: %v games=%v solved=%v errors=%v score total/avg=%v/%.1f time=%.2fs\n",
+				i+1, s.Bot, s.Games, s.Solved, s.Errors, s.Score, s.ScoreAvg, s.Time)
+		}
+
+		return cw.Error()
+	}
+
+	return fmt.Errorf("invalid format %q", format)
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// the test binary runs as a bot (see testBot) when ARROWS_TEST_BOT is set,
+// playing as the bot named after "--"
+func TestBotHelper(t *testing.T) {
+	if os.Getenv("ARROWS_TEST_BOT") == "" || flag.NArg() == 0 {
+		t.Skip("only run as a bot")
+	}
+
+	os.Exit(runTestBot(flag.Arg(0), os.Stdin, os.Stdout))
+}
+
+// return the command for one of the test bots (see runTestBot)
+func testBot(t *testing.T, name string) string {
+	t.Setenv("ARROWS_TEST_BOT", "1")
+	return os.Args[0] + " -test.run=^TestBotHelper$ -- " + name
+}
+
+// play as a bot, checking the messages from the game:
+//
+//	greedy:   move the first free arrow (in reading order), shuffle when there are none
+//	shuffle:  move 10 arrows like greedy, shuffle, then resign
+//	shuffler: always shuffle
+//	slow:     don't reply in time
+//	invalid:  reply with an invalid command
+//	exit:     exit before the end of the game
+//
+// The exit code is 2 if the game sends an invalid message.
+func runTestBot(name string, in io.Reader, out io.Writer) int {
+	var g Game
+
+	moves, shuffles := 0, 0
+	scanner := bufio.NewScanner(in)
+
+	fail := func(format string, args ...interface{}) int {
+		fmt.Fprintf(os.Stderr, "bot %v: "+format+"\n", append([]interface{}{name}, args...)...)
+		return 2
+	}
+
+	for scanner.Scan() {
+		args := strings.Fields(scanner.Text())
+		if len(args) == 0 {
+			return fail("empty line")
+		}
+
+		switch args[0] {
+		case "board":
+			var w, h, remain, score int
+
+			if n, _ := fmt.Sscanf(scanner.Text(), "board %d %d %d %d %d", &w, &h, &remain, &shuffles, &score); n != 5 {
+				return fail("invalid board %q", scanner.Text())
+			}
+
+			g = Game{Width: w + 2, Height: h + 2, cellwidth: 1, cellheight: 1}
+			g.Screen = append(g.Screen, make([]Dir, w+2))
+
+			for y := 0; y < h; y++ {
+				if !scanner.Scan() || len(scanner.Text()) != w {
+					return fail("invalid board line %v: %q", y, scanner.Text())
+				}
+
+				row := make([]Dir, w+2)
+				for x, c := range []byte(scanner.Text()) {
+					row[x+1] = InvalidDir
+
+					for d, gc := range gridChars {
+						if c == gc {
+							row[x+1] = d
+						}
+					}
+
+					if row[x+1] == InvalidDir {
+						return fail("invalid cell %q", c)
+					}
+
+					if row[x+1] != Empty {
+						g.Count++
+					}
+				}
+
+				g.Screen = append(g.Screen, row)
+			}
+
+			g.Screen = append(g.Screen, make([]Dir, w+2))
+
+			if g.Count != remain {
+				return fail("%v arrows on the board, %v remaining", g.Count, remain)
+			}
+
+		case "go":
+			switch name {
+			case "slow":
+				time.Sleep(10 * time.Second)
+				fmt.Fprintln(out, "resign")
+
+			case "invalid":
+				fmt.Fprintln(out, "jump 1 1")
+
+			case "exit":
+				return 0
+
+			case "shuffler":
+				fmt.Fprintln(out, "shuffle")
+
+			case "shuffle":
+				if moves == 10 {
+					if shuffles == 0 {
+						fmt.Fprintln(out, "shuffle")
+					} else {
+						fmt.Fprintln(out, "resign")
+					}
+
+					break
+				}
+
+				fallthrough
+
+			default:
+				fmt.Fprintln(out, "# looking for a free arrow") // comments are ignored
+
+				if x, y, ok := firstFree(&g); ok {
+					fmt.Fprintf(out, "move %v %v\n", x-1, y-1)
+					moves++
+				} else {
+					fmt.Fprintln(out, "shuffle")
+				}
+			}
+
+		case "result":
+			if len(args) != 2 || args[1] != updateNames[Remove] {
+				return fail("a free arrow was not removed: %q", scanner.Text())
+			}
+
+		case "gameover":
+			return 0
+
+		default:
+			return fail("invalid message %q", scanner.Text())
+		}
+	}
+
+	return fail("no gameover")
+}
+
+// the first free arrow, in reading order
+func firstFree(g *Game) (int, int, bool) {
+	for y := 1; y < g.Height-1; y++ {
+		for x := 1; x < g.Width-1; x++ {
+			if g.Screen[y][x] != Empty && g.IsFree(x, y) {
+				return x, y, true
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
+// set the board size and the scoring rules for the bot games
+func botRules(t *testing.T, rules ScoreRules) {
+	w, h, r := gameWidth, gameHeight, scoreRules
+	t.Cleanup(func() { gameWidth, gameHeight, scoreRules = w, h, r })
+
+	gameWidth, gameHeight, scoreRules = 12, 10, rules
+}
+
+func TestBotProtocol(t *testing.T) {
+	botRules(t, ClassicRules)
+
+	res := playBot(testBot(t, "greedy"), 1, 42, 5*time.Second)
+	if !res.Solved || res.Error != "" || res.Seed != 42 {
+		t.Fatalf("greedy bot: %+v", res)
+	}
+
+	// the same game, played here
+	var g Game
+	g.SetupSeed(gameWidth, gameHeight, 1, 1, 42)
+
+	for g.Count > 0 {
+		if x, y, ok := firstFree(&g); ok {
+			g.Update(x, y, Move)
+		} else {
+			g.Shuffle(shuffleMode)
+		}
+	}
+
+	if res.Moves != g.Moves || res.Shuffles != g.Shuffles || res.MaxSeq != g.MaxSeq || res.Score != g.ComputeScore() {
+		t.Errorf("greedy bot: %+v, want moves=%v shuffles=%v maxseq=%v score=%v",
+			res, g.Moves, g.Shuffles, g.MaxSeq, g.ComputeScore())
+	}
+}
+
+func TestBotErrors(t *testing.T) {
+	botRules(t, ClassicRules)
+
+	for _, bt := range []struct{ bot, err string }{
+		{"slow", "timeout"},
+		{"invalid", `invalid command "jump 1 1"`},
+		{"exit", "bot exited"},
+	} {
+		start := time.Now()
+
+		res := playBot(testBot(t, bt.bot), 1, 42, 2*time.Second)
+		if res.Error != bt.err || res.Solved || res.Score != 0 {
+			t.Errorf("%v bot: %+v, want error %q", bt.bot, res, bt.err)
+		}
+
+		// a slow bot is killed, not waited for
+		if d := time.Since(start); d > 5*time.Second {
+			t.Errorf("%v bot: the game took %v", bt.bot, d)
+		}
+	}
+}
+
+func TestBotShuffles(t *testing.T) {
+	// the shuffles are limited by the budget
+	botRules(t, ClassicRules.WithBudget(2))
+
+	res := playBot(testBot(t, "shuffler"), 1, 42, 5*time.Second)
+	if res.Error != "no shuffles left" || res.Shuffles != 2 || res.Score != 0 {
+		t.Errorf("shuffler bot: %+v", res)
+	}
+
+	// and each shuffle costs points
+	limited := playBot(testBot(t, "shuffle"), 1, 42, 5*time.Second)
+
+	scoreRules = ClassicRules
+	free := playBot(testBot(t, "shuffle"), 1, 42, 5*time.Second)
+
+	if limited.Error != "" || limited.Shuffles != 1 || free.Score-limited.Score != budgetPenalty {
+		t.Errorf("shuffle bot: %+v with the shuffle budget, %+v without", limited, free)
+	}
+}
+
+func TestBotTournament(t *testing.T) {
+	botRules(t, ClassicRules)
+
+	seed := gameSeed
+	defer func() { gameSeed = seed }()
+	gameSeed = 7
+
+	invalid, greedy := testBot(t, "invalid"), testBot(t, "greedy")
+	bots := []string{invalid, greedy}
+
+	var out bytes.Buffer
+	if err := runTournament(bots, 2, 5*time.Second, "json", &out); err != nil {
+		t.Fatal(err)
+	}
+
+	var tour struct {
+		Summary []BotSummary `json:"summary"`
+		Results []BotResult  `json:"results"`
+	}
+
+	if err := json.Unmarshal(out.Bytes(), &tour); err != nil {
+		t.Fatal(err)
+	}
+
+	// the bots play the same boards, and the best score comes first
+	if len(tour.Results) != 4 || tour.Results[0].Seed != tour.Results[1].Seed || tour.Results[2].Seed != tour.Results[3].Seed ||
+		tour.Results[0].Seed == tour.Results[2].Seed {
+		t.Errorf("results: %+v", tour.Results)
+	}
+
+	if len(tour.Summary) != 2 {
+		t.Fatalf("summary: %+v", tour.Summary)
+	}
+
+	if s := tour.Summary[0]; s.Bot != greedy || s.Games != 2 || s.Solved != 2 || s.Errors != 0 || s.Score == 0 {
+		t.Errorf("first: %+v", s)
+	}
+
+	if s := tour.Summary[1]; s.Bot != invalid || s.Games != 2 || s.Solved != 0 || s.Errors != 2 || s.Score != 0 {
+		t.Errorf("second: %+v", s)
+	}
+
+	// the first board again, as csv
+	out.Reset()
+	if err := runTournament(bots, 1, 5*time.Second, "csv", &out); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 3 || records[0][0] != "bot" || records[2][0] != greedy || records[2][7] != fmt.Sprint(tour.Results[1].Score) {
+		t.Errorf("csv: %q", records)
+	}
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The configuration file is a JSON object with the default value for the command line options
+// and the key bindings (a list of space separated keys for each action), i.e.:
+//
+//	{
+//	  "flags": {"width": 30, "height": 15, "audio": false, "shuffle": "left"},
+//	  "keys": {"undo": "z", "reset": "n", "quit": "esc q"}
+//	}
+//
+// Keys are single characters (case sensitive) or "esc".
+type Config struct {
+	Flags map[string]interface{} `json:"flags"`
+	Keys  map[string]string      `json:"keys"`
+}
+
+// actions that can be bound to keys
+const (
+	actionUndo       = "undo"
+	actionReset      = "reset"
+	actionShuffle    = "shuffle"
+	actionHint       = "hint"
+	actionAutoplay   = "autoplay"
+	actionQuit       = "quit"
+	actionMute       = "mute"
+	actionVolumeDown = "volume-down"
+	actionVolumeUp   = "volume-up"
+	actionScoreboard = "scoreboard"
+	actionStats      = "stats"
+	actionSnapshot   = "snapshot"
+	actionReplay     = "replay"
+	actionHelp       = "help"
+	actionZoomIn     = "zoom-in"
+	actionZoomOut    = "zoom-out"
+)
+
+// the actions (in the order they are listed in the help) and the default keys
+var keyActions = []struct {
+	Action      string
+	Keys        string
+	Description string
+}{
+	{actionUndo, "u", "undo last move"},
+	{actionReset, "r", "reset game"},
+	{actionShuffle, "s", "reshuffle game"},
+	{actionHint, "h", "help: remove all free arrows"},
+	{actionAutoplay, "p", "autoplay (again to stop)"},
+	{actionStats, "i", "show/hide statistics"},
+	{actionScoreboard, "b", "show/hide scoreboard"},
+	{actionMute, "m", "mute/unmute audio"},
+	{actionVolumeDown, "[", "volume down"},
+	{actionVolumeUp, "]", "volume up"},
+	{actionSnapshot, "c", "save a snapshot (PNG)"},
+	{actionReplay, "g", "save the replay (GIF)"},
+	{actionZoomIn, "+ =", "zoom in (graphical UI)"},
+	{actionZoomOut, "_", "zoom out (graphical UI)"},
+	{actionHelp, "?", "show/hide this help"},
+	{actionQuit, "esc", "quit"},
+}
+
+// key -> action
+var keyBindings = bindKeys(nil)
+
+// the keys set in the configuration file (action -> keys)
+var customKeys = map[string]string{}
+
+// the graphical UI also quits with Q and X (as it always did), unless the quit keys
+// are set in the configuration file or the keys are bound to another action
+var gioQuitKeys = []string{"q", "Q", "x", "X"}
+
+// in the terminal UI the vi keys move the cursor, unless they are set in the configuration file
+// (they take precedence over the default bindings), so the hint is also bound to F and f
+// (unless the hint keys are set in the configuration file)
+const viKeys = "hjklnN0$HL"
+
+var termHintKeys = []string{"f", "F"}
+
+// return the path of the configuration file (in the user configuration directory)
+func configPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, "arrows", "config.json")
+}
+
+// read the configuration file, and use it to set the default value of the command line options
+// (this should be called after the options are defined and before they are parsed)
+// and the key bindings
+func loadConfig() {
+	path := configPath()
+	if path == "" {
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Println(err)
+		}
+
+		return
+	}
+
+	defer f.Close()
+
+	var config Config
+
+	dec := json.NewDecoder(f)
+	dec.UseNumber() // keep numbers (i.e. the seed) as they are
+
+	if err := dec.Decode(&config); err != nil {
+		log.Fatalf("cannot read %v: %v", path, err)
+	}
+
+	for name, value := range config.Flags {
+		if flag.Lookup(name) == nil {
+			log.Printf("%v: unknown option %q", path, name)
+			continue
+		}
+
+		if err := flag.Set(name, fmt.Sprint(value)); err != nil {
+			log.Fatalf("%v: invalid value for %q: %v", path, name, err)
+		}
+	}
+
+	for action, keys := range config.Keys {
+		if keysFor(action) == nil {
+			log.Printf("%v: unknown action %q", path, action)
+			delete(config.Keys, action)
+			continue
+		}
+
+		for _, k := range strings.Fields(keys) {
+			if len([]rune(k)) != 1 && strings.ToLower(k) != "esc" {
+				log.Fatalf("%v: invalid key %q for %q", path, k, action)
+			}
+		}
+	}
+
+	keyBindings = bindKeys(config.Keys)
+	customKeys = config.Keys
+}
+
+// the keys bound to an action by default (nil if the action doesn't exist)
+func keysFor(action string) []string {
+	for _, ka := range keyActions {
+		if ka.Action == action {
+			return strings.Fields(ka.Keys)
+		}
+	}
+
+	return nil
+}
+
+// return the key -> action map, with the default bindings replaced by the custom ones
+// (a key bound to an action in custom is removed from the action it was bound to by default).
+// The default letters are bound with and without shift, the custom keys as they are.
+func bindKeys(custom map[string]string) map[string]string {
+	bindings := map[string]string{}
+
+	for _, ka := range keyActions {
+		if _, ok := custom[ka.Action]; ok {
+			continue
+		}
+
+		for _, k := range strings.Fields(ka.Keys) {
+			bindings[k] = ka.Action
+
+			if len(k) == 1 {
+				bindings[strings.ToUpper(k)] = ka.Action
+			}
+		}
+	}
+
+	for action, keys := range custom {
+		for _, k := range strings.Fields(keys) {
+			bindings[keyName(k)] = action
+		}
+	}
+
+	return bindings
+}
+
+// return the name used for the key bindings (named keys, like "esc", are not case sensitive)
+func keyName(key string) string {
+	if len([]rune(key)) > 1 {
+		return strings.ToLower(key)
+	}
+
+	return key
+}
+
+// return the action bound to a key ("" if there is none)
+func keyAction(key string) string {
+	return keyBindings[keyName(key)]
+}
+
+// return true if the key is set in the configuration file
+func customKey(key string) bool {
+	for _, keys := range customKeys {
+		for _, k := range strings.Fields(keys) {
+			if keyName(k) == keyName(key) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// return the action bound to a key in the graphical UI
+func gioKeyAction(key string) string {
+	if action := keyAction(key); action != "" {
+		return action
+	}
+
+	if _, ok := customKeys[actionQuit]; !ok && containsKey(gioQuitKeys, key) {
+		return actionQuit
+	}
+
+	return ""
+}
+
+// return the action bound to a key in the terminal UI ("" for the vi keys)
+func termKeyAction(key string) string {
+	if len(key) == 1 && strings.Contains(viKeys, key) && !customKey(key) {
+		return ""
+	}
+
+	if action := keyAction(key); action != "" {
+		return action
+	}
+
+	if _, ok := customKeys[actionHint]; !ok && containsKey(termHintKeys, key) {
+		return actionHint
+	}
+
+	return ""
+}
+
+func containsKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// return the list of keys bound to each action in the terminal UI, with a description of the action
+func keyHelp() (lines []string) {
+	for _, ka := range keyActions {
+		var keys []string
+		for k := range keyBindings {
+			if termKeyAction(k) == ka.Action {
+				keys = append(keys, k)
+			}
+		}
+
+		for _, k := range termHintKeys {
+			if _, bound := keyBindings[k]; !bound && termKeyAction(k) == ka.Action {
+				keys = append(keys, k)
+			}
+		}
+
+		if len(keys) == 0 {
+			continue
+		}
+
+		sort.Strings(keys)
+		lines = append(lines, fmt.Sprintf("%-7s %v", strings.Join(keys, " "), ka.Description))
+	}
+
+	return
+}
@@ -0,0 +1,70 @@
+package main
+
+import "testing"
+
+// check the actions bound to a key in the terminal and in the graphical UI
+func checkKey(t *testing.T, key, term, gio string) {
+	t.Helper()
+
+	if action := termKeyAction(key); action != term {
+		t.Errorf("terminal %q: got %q, want %q", key, action, term)
+	}
+	if action := gioKeyAction(key); action != gio {
+		t.Errorf("graphical %q: got %q, want %q", key, action, gio)
+	}
+}
+
+func TestQuitKeys(t *testing.T) {
+	defer func() { keyBindings, customKeys = bindKeys(nil), nil }()
+
+	check := func(key, term, gio string) {
+		t.Helper()
+		checkKey(t, key, term, gio)
+	}
+
+	// by default Q and X only quit the graphical UI
+	check("esc", actionQuit, actionQuit)
+	check("q", "", actionQuit)
+	check("X", "", actionQuit)
+
+	// unless they are bound to something else
+	customKeys = map[string]string{actionUndo: "q"}
+	keyBindings = bindKeys(customKeys)
+	check("q", actionUndo, actionUndo)
+	check("Q", "", actionQuit)
+	check("x", "", actionQuit)
+
+	// or the quit keys are set in the configuration file
+	customKeys = map[string]string{actionQuit: "ESC q"}
+	keyBindings = bindKeys(customKeys)
+	check("esc", actionQuit, actionQuit)
+	check("q", actionQuit, actionQuit)
+	check("x", "", "")
+}
+
+func TestKeyCase(t *testing.T) {
+	defer func() { keyBindings, customKeys = bindKeys(nil), nil }()
+
+	check := func(key, term, gio string) {
+		t.Helper()
+		checkKey(t, key, term, gio)
+	}
+
+	// the default letters are bound with and without shift,
+	// but in the terminal UI h and H move the cursor and F and f are the hint
+	check("u", actionUndo, actionUndo)
+	check("U", actionUndo, actionUndo)
+	check("h", "", actionHint)
+	check("H", "", actionHint)
+	check("f", actionHint, "")
+	check("F", actionHint, "")
+
+	// the custom keys are case sensitive and take precedence over the vi keys
+	customKeys = map[string]string{actionHint: "h", actionUndo: "H"}
+	keyBindings = bindKeys(customKeys)
+	check("h", actionHint, actionHint)
+	check("H", actionUndo, actionUndo)
+	check("u", "", "")
+	check("f", "", "")
+	check("l", "", "")
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"time"
+)
+
+const dateFormat = "2006-01-02"
+
+// the result of a daily challenge
+type DailyInfo struct {
+	Date   string
+	Moves  int
+	MaxSeq int
+	Score  int
+}
+
+// daily challenge results, by score key (board size)
+type Daily map[string][]DailyInfo
+
+var daily = Daily{}
+
+// the date of the daily challenge ("" if not playing the daily challenge)
+var dailyDate string
+
+// return the seed for the daily challenge,
+// so that everybody gets the same board on the same day (for the same board size)
+func dailySeed(date string, w, h int) int64 {
+	hash := fnv.New64a()
+	fmt.Fprintf(hash, "arrows/%v/%vx%v", date, w, h)
+	return int64(hash.Sum64() &^ (1 << 63))
+}
+
+// the current date, in UTC so that the daily board is the same in every time zone
+func today() string {
+	return time.Now().UTC().Format(dateFormat)
+}
+
+// return the result for the specified date, if the challenge was completed
+func (d Daily) Played(width, height int, date string) *DailyInfo {
+	for _, di := range d[scoreKey(width, height)] {
+		if di.Date == date {
+			return &di
+		}
+	}
+
+	return nil
+}
+
+// add the result of the daily challenge.
+// returns false if the challenge for this date was already completed.
+func (d Daily) Update(g *Game, date string) (*DailyInfo, bool) {
+	if di := d.Played(g.Width, g.Height, date); di != nil {
+		return di, false
+	}
+
+	g.ComputeScore()
+
+	info := DailyInfo{Date: date, Moves: g.Moves, MaxSeq: g.MaxSeq, Score: g.FinalScore}
+
+	key := scoreKey(g.Width, g.Height)
+	dd := append(d[key], info)
+	sort.Slice(dd, func(i, j int) bool { return dd[i].Date < dd[j].Date })
+	d[key] = dd
+	return &info, true
+}
+
+// return the daily results, sorted by date
+func (d Daily) Get(width, height int) []DailyInfo {
+	return d[scoreKey(width, height)]
+}
+
+// return the current streak (consecutive days completed, up to today or yesterday)
+// and the longest streak
+func (d Daily) Streaks(width, height int) (current, longest int) {
+	var last time.Time
+
+	streak := 0
+
+	for _, di := range d.Get(width, height) {
+		t, err := time.Parse(dateFormat, di.Date)
+		if err != nil {
+			continue
+		}
+
+		if !last.IsZero() && t.Equal(last.AddDate(0, 0, 1)) {
+			streak++
+		} else {
+			streak = 1
+		}
+
+		if streak > longest {
+			longest = streak
+		}
+
+		last = t
+	}
+
+	if t, err := time.Parse(dateFormat, today()); err == nil && !last.IsZero() {
+		if t.Equal(last) || t.Equal(last.AddDate(0, 0, 1)) {
+			current = streak
+		}
+	}
+
+	return
+}
+
+// return the index of the best daily result (-1 if there are no results)
+func (d Daily) Best(width, height int) int {
+	best := -1
+
+	for i, di := range d.Get(width, height) {
+		if best < 0 || di.Score > d.Get(width, height)[best].Score {
+			best = i
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// difficulty levels
+const (
+	Easy   = "easy"
+	Medium = "medium"
+	Hard   = "hard"
+)
+
+// the rating bands for each level
+// (the limits split the random 20x20 boards in three groups of about the same size)
+var levelBands = []struct {
+	Level    string
+	Min, Max int
+}{
+	{Easy, 0, 54},
+	{Medium, 55, 59},
+	{Hard, 60, 100},
+}
+
+const (
+	maxRegenerate   = 100                     // boards generated looking for the requested difficulty
+	regenerateCells = maxRegenerate * 20 * 20 // cells rated looking for the requested difficulty (fewer boards when they are large)
+)
+
+// difficulty estimate for a board
+type Difficulty struct {
+	Depth    int     `json:"depth"`    // depth of the blocking dependency graph
+	Free     int     `json:"free"`     // free arrows at start
+	Solvable bool    `json:"solvable"` // can be solved without shuffling
+	Shuffles int     `json:"shuffles"` // shuffles needed by the solver
+	Time     float64 `json:"time"`     // seconds spent by the solver (not used for the rating)
+	Rating   int     `json:"rating"`   // from 0 (easiest) to 100 (hardest)
+	Level    string  `json:"level"`    // easy, medium or hard
+}
+
+func (d Difficulty) String() string {
+	if d.Level == "" {
+		return "unrated"
+	}
+
+	return fmt.Sprintf("%v (%v)", d.Level, d.Rating)
+}
+
+// check that a difficulty level is valid ("" means any difficulty)
+func validLevel(level string) bool {
+	if level == "" {
+		return true
+	}
+
+	for _, b := range levelBands {
+		if b.Level == level {
+			return true
+		}
+	}
+
+	return false
+}
+
+// return the difficulty level for a rating
+func ratingLevel(rating int) string {
+	for _, b := range levelBands {
+		if rating <= b.Max {
+			return b.Level
+		}
+	}
+
+	return Hard
+}
+
+// return how far a rating is from the band of a level (0 if it's in the band)
+func levelDistance(rating int, level string) int {
+	for _, b := range levelBands {
+		if b.Level != level {
+			continue
+		}
+
+		if rating < b.Min {
+			return b.Min - rating
+		}
+
+		if rating > b.Max {
+			return rating - b.Max
+		}
+
+		return 0
+	}
+
+	return 0
+}
+
+// return the short name of a level, for the scoreboard
+func levelInitial(level string) string {
+	if level == "" {
+		return " "
+	}
+
+	return strings.ToUpper(level[:1])
+}
+
+// remove all the free arrows at the same time until none is left,
+// and return the number of layers removed (the depth of the blocking dependency graph:
+// the arrows in a layer are only blocked by arrows in the previous layers)
+// and the number of arrows left (blocking each other, so that they can only be removed by shuffling)
+func (g *Game) peel() (depth, left int) {
+	c := g.Clone()
+	left = c.Count
+
+	var cells []Cell
+
+	for {
+		cells = cells[:0]
+		c.index().each(func(x, y int) { cells = append(cells, Cell{X: x, Y: y}) })
+
+		if len(cells) == 0 {
+			return
+		}
+
+		for _, cell := range cells {
+			c.set(cell.X, cell.Y, Empty)
+		}
+
+		depth++
+		left -= len(cells)
+	}
+}
+
+// estimate how difficult the board is, from the depth of the blocking dependency graph,
+// the free arrows at start, if the board can be solved without shuffling
+// and how long the solver (the same used for autoplay) takes to clear it.
+//
+// The solver effort is measured by the random shuffles it needs, so that the rating is the same on every machine
+// and with every shuffle mode (the solver gives up when the board is already rated as the slowest).
+func (g *Game) Rate() Difficulty {
+	d := Difficulty{Free: g.Free()}
+
+	depth, left := g.peel()
+	d.Depth = depth
+	d.Solvable = left == 0
+
+	// each factor goes from 0 (easy) to 1 (hard), relative to the number of rows and columns
+	lines := float64(g.Width + g.Height - 4)
+
+	start := time.Now()
+	_, d.Shuffles, _ = autoplay(g.Clone(), ShuffleRandom, int(math.Ceil(lines/2)))
+	d.Time = time.Since(start).Seconds()
+
+	few := 1 - clamp01(float64(d.Free)/(1.5*lines))
+	deep := clamp01(float64(d.Depth) / lines)
+	slow := clamp01(float64(d.Shuffles) / (lines / 2))
+	stuck := 1.0
+
+	if d.Solvable {
+		stuck = 0
+	}
+
+	d.Rating = int(100*(0.25*stuck+0.3*few+0.15*deep+0.3*slow) + 0.5)
+	d.Level = ratingLevel(d.Rating)
+	return d
+}
+
+// generate new boards (with seeds derived from the current one, so that the result can be repeated)
+// until one is rated at the requested level, or keep the closest one
+// (large boards are slow to rate, so fewer boards are generated: the budget is in cells, not time,
+// so that the same seed always gives the same board)
+func (g *Game) regenerate(level string) {
+	rng := rand.New(rand.NewSource(g.Seed))
+	budget := regenerateCells / (g.Width * g.Height)
+	if budget > maxRegenerate {
+		budget = maxRegenerate
+	}
+
+	bestSeed, bestDistance := g.Seed, -1
+	var best Difficulty
+
+	for i := 0; ; i++ {
+		g.Difficulty = g.Rate()
+
+		dist := levelDistance(g.Difficulty.Rating, level)
+		if dist == 0 {
+			return
+		}
+
+		if bestDistance < 0 || dist < bestDistance {
+			bestSeed, bestDistance, best = g.Seed, dist, g.Difficulty
+		}
+
+		if i >= budget {
+			break
+		}
+
+		g.SetupSeed(g.Width, g.Height, g.cellwidth, g.cellheight, rng.Int63())
+	}
+
+	g.SetupSeed(g.Width, g.Height, g.cellwidth, g.cellheight, bestSeed)
+	g.Difficulty = best // already rated
+}
+
+func clamp01(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+
+	if v > 1 {
+		return 1
+	}
+
+	return v
+}
@@ -0,0 +1,58 @@
+package main
+
+import "testing"
+
+func TestRateRepeatable(t *testing.T) {
+	var g Game
+	g.SetupSeed(22, 22, 1, 1, 7)
+
+	d1, d2 := g.Rate(), g.Rate()
+	d1.Time, d2.Time = 0, 0
+
+	if d1 != d2 {
+		t.Errorf("the same board rated %+v and %+v", d1, d2)
+	}
+}
+
+func TestRegenerate(t *testing.T) {
+	for _, level := range []string{Easy, Medium, Hard} {
+		var g1, g2 Game
+
+		g1.SetupSeed(22, 22, 1, 1, 7)
+		g1.regenerate(level)
+
+		g2.SetupSeed(22, 22, 1, 1, 7)
+		g2.regenerate(level)
+
+		if g1.Seed != g2.Seed || g1.Difficulty.Rating != g2.Difficulty.Rating {
+			t.Errorf("%v: seed %v (%v), then %v (%v)", level, g1.Seed, g1.Difficulty, g2.Seed, g2.Difficulty)
+		}
+
+		// the board kept is not rated again, but its rating is the same
+		if d := g1.Rate(); d.Rating != g1.Difficulty.Rating || d.Level != g1.Difficulty.Level {
+			t.Errorf("%v: seed %v rated %v, kept as %v", level, g1.Seed, d, g1.Difficulty)
+		}
+	}
+}
+
+func TestSetupRating(t *testing.T) {
+	defer func() { gameSeed, gameDifficulty, seedRated = 0, "", false }()
+
+	gameSeed, gameDifficulty = 7, ""
+
+	var g Game
+	g.Setup(22, 22, 1, 1)
+
+	// every board is rated, without generating new ones
+	if g.Seed != 7 || g.Difficulty.Level == "" || g.Difficulty.Rating != g.Rate().Rating {
+		t.Errorf("board without a difficulty: seed %v, difficulty %v", g.Seed, g.Difficulty)
+	}
+
+	// the board chosen by the race host is played as it is
+	gameDifficulty, seedRated = Hard, true
+	g.Setup(22, 22, 1, 1)
+
+	if g.Seed != 7 || g.Difficulty.Level == "" {
+		t.Errorf("chosen board: seed %v, difficulty %v", g.Seed, g.Difficulty)
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+// Index of the free arrows.
+//
+// An arrow is free if the cells in front of it are only arrows in the same direction followed by empty cells,
+// so in each row only the run of Right arrows ending at the rightmost arrow and the run of Left arrows
+// starting at the leftmost arrow can be free (and the same for Up/Down arrows in each column).
+//
+// The index keeps the first and last arrow of each row and column, and the free runs,
+// and when a cell changes only its row and column are updated.
+//
+// Arrows on the border (only the win banner can be there) are never free, and block the arrows behind them.
+type freeIndex struct {
+	rows []lineIndex
+	cols []lineIndex
+
+	dirtyRows []int // rows and columns that changed since the last update
+	dirtyCols []int
+
+	count int // number of free arrows
+}
+
+type lineIndex struct {
+	first, last int // position of the first and last arrow (first > last if there are none)
+	head, tail  int // length of the free runs at the start and at the end of the line
+	dirty       bool
+}
+
+// build the index for the current board
+func newFreeIndex(g *Game) *freeIndex {
+	ix := &freeIndex{
+		rows: make([]lineIndex, g.Height),
+		cols: make([]lineIndex, g.Width),
+	}
+
+	for y := range ix.rows {
+		ix.rows[y] = lineIndex{first: g.Width, last: -1}
+	}
+
+	for x := range ix.cols {
+		ix.cols[x] = lineIndex{first: g.Height, last: -1}
+	}
+
+	for y, row := range g.Screen {
+		for x, col := range row {
+			if col != Empty {
+				ix.set(x, y, col)
+			}
+		}
+	}
+
+	ix.update(g, nil)
+	return ix
+}
+
+// register a change in cell x,y
+func (ix *freeIndex) set(x, y int, d Dir) {
+	r, c := &ix.rows[y], &ix.cols[x]
+
+	if d != Empty { // the line can only get longer (removed arrows are checked in update)
+		if x < r.first {
+			r.first = x
+		}
+		if x > r.last {
+			r.last = x
+		}
+		if y < c.first {
+			c.first = y
+		}
+		if y > c.last {
+			c.last = y
+		}
+	}
+
+	if !r.dirty {
+		r.dirty = true
+		ix.dirtyRows = append(ix.dirtyRows, y)
+	}
+
+	if !c.dirty {
+		c.dirty = true
+		ix.dirtyCols = append(ix.dirtyCols, x)
+	}
+}
+
+// update the free runs of the lines that changed,
+// calling newfree (if not nil) for each cell in the updated runs
+func (ix *freeIndex) update(g *Game, newfree func(x, y int)) {
+	for _, y := range ix.dirtyRows {
+		r := &ix.rows[y]
+		r.dirty = false
+		ix.count -= r.head + r.tail
+
+		row := g.Screen[y]
+
+		for r.first <= r.last && row[r.first] == Empty {
+			r.first++
+		}
+		for r.last >= r.first && row[r.last] == Empty {
+			r.last--
+		}
+
+		r.head, r.tail = 0, 0
+
+		if y > 0 && y < g.Height-1 {
+			for x := r.first; x > 0 && x < g.Width-1 && x <= r.last && row[x] == Left; x++ {
+				r.head++
+			}
+			for x := r.last; x > 0 && x < g.Width-1 && x >= r.first && row[x] == Right; x-- {
+				r.tail++
+			}
+		}
+
+		ix.count += r.head + r.tail
+
+		if newfree != nil {
+			for x := r.first; x < r.first+r.head; x++ {
+				newfree(x, y)
+			}
+			for x := r.last; x > r.last-r.tail; x-- {
+				newfree(x, y)
+			}
+		}
+	}
+
+	for _, x := range ix.dirtyCols {
+		c := &ix.cols[x]
+		c.dirty = false
+		ix.count -= c.head + c.tail
+
+		for c.first <= c.last && g.Screen[c.first][x] == Empty {
+			c.first++
+		}
+		for c.last >= c.first && g.Screen[c.last][x] == Empty {
+			c.last--
+		}
+
+		c.head, c.tail = 0, 0
+
+		if x > 0 && x < g.Width-1 {
+			for y := c.first; y > 0 && y < g.Height-1 && y <= c.last && g.Screen[y][x] == Up; y++ {
+				c.head++
+			}
+			for y := c.last; y > 0 && y < g.Height-1 && y >= c.first && g.Screen[y][x] == Down; y-- {
+				c.tail++
+			}
+		}
+
+		ix.count += c.head + c.tail
+
+		if newfree != nil {
+			for y := c.first; y < c.first+c.head; y++ {
+				newfree(x, y)
+			}
+			for y := c.last; y > c.last-c.tail; y-- {
+				newfree(x, y)
+			}
+		}
+	}
+
+	ix.dirtyRows = ix.dirtyRows[:0]
+	ix.dirtyCols = ix.dirtyCols[:0]
+}
+
+// call fn for each free arrow
+func (ix *freeIndex) each(fn func(x, y int)) {
+	for y, r := range ix.rows {
+		for x := r.first; x < r.first+r.head; x++ {
+			fn(x, y)
+		}
+		for x := r.last; x > r.last-r.tail; x-- {
+			fn(x, y)
+		}
+	}
+
+	for x, c := range ix.cols {
+		for y := c.first; y < c.first+c.head; y++ {
+			fn(x, y)
+		}
+		for y := c.last; y > c.last-c.tail; y-- {
+			fn(x, y)
+		}
+	}
+}
+
+// call fn for the first and last arrow of each row and column (that could be free),
+// with the direction that would make them free
+func (ix *freeIndex) ends(g *Game, fn func(x, y int, d Dir)) {
+	for y, r := range ix.rows {
+		if r.first <= r.last && y > 0 && y < g.Height-1 {
+			fn(r.first, y, Left)
+			fn(r.last, y, Right)
+		}
+	}
+
+	for x, c := range ix.cols {
+		if c.first <= c.last && x > 0 && x < g.Width-1 {
+			fn(x, c.first, Up)
+			fn(x, c.last, Down)
+		}
+	}
+}
+
+// return the index of the free arrows, building it if needed
+func (g *Game) index() *freeIndex {
+	if g.free == nil {
+		g.free = newFreeIndex(g)
+	} else {
+		g.free.update(g, nil)
+	}
+
+	return g.free
+}
+
+// change a cell, keeping the index up to date
+func (g *Game) set(x, y int, d Dir) {
+	g.Screen[y][x] = d
+
+	if g.free != nil {
+		g.free.set(x, y, d)
+	}
+}
+
+// a min-heap of cell positions (y*width+x), to visit the cells in order
+type cellHeap []int
+
+func (h cellHeap) Len() int            { return len(h) }
+func (h cellHeap) Less(i, j int) bool  { return h[i] < h[j] }
+func (h cellHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *cellHeap) Push(x interface{}) { *h = append(*h, x.(int)) }
+
+func (h *cellHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// the free arrows, checking every cell (the way the game worked before the index)
+func scanFree(g *Game) (free []Cell) {
+	for y := 1; y < g.Height-1; y++ {
+		for x := 1; x < g.Width-1; x++ {
+			if g.IsFree(x, y) {
+				free = append(free, Cell{X: x, Y: y, D: g.Screen[y][x]})
+			}
+		}
+	}
+
+	return
+}
+
+// the free arrows in the index, in reading order
+func indexFree(g *Game) (free []Cell) {
+	g.index().each(func(x, y int) {
+		free = append(free, Cell{X: x, Y: y, D: g.Screen[y][x]})
+	})
+
+	sort.Slice(free, func(i, j int) bool {
+		return free[i].Y*g.Width+free[i].X < free[j].Y*g.Width+free[j].X
+	})
+
+	return
+}
+
+// PlayTurn without the index
+func scanPlayTurn(g *Game) Updates {
+	moved := Invalid
+
+	for y := 1; y < g.Height-1; y++ {
+		for x := 1; x < g.Width-1; x++ {
+			x, y := g.ScreenCoords(0, 0, x, y)
+			if _, _, mov := g.Update(x, y, Remove); mov > moved {
+				moved = mov
+			}
+		}
+	}
+
+	return moved
+}
+
+func TestFreeIndex(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	for _, seed := range []int64{1, 2, 3} {
+		var g Game
+		g.SetupSeed(16, 12, 1, 1, seed)
+
+		for i := 0; i < 2000 && !g.Winner(); i++ {
+			var op string
+
+			switch n := rnd.Intn(20); {
+			case n < 12:
+				op = "move"
+				g.Update(rnd.Intn(g.Width), rnd.Intn(g.Height), Move)
+			case n < 14:
+				op = "remove"
+				g.Update(rnd.Intn(g.Width), rnd.Intn(g.Height), Remove)
+			case n < 18:
+				op = "undo"
+				g.Undo()
+			case n < 19:
+				op = "shuffle"
+				g.Shuffle(ShuffleMode(rnd.Intn(int(ShuffleMinimal) + 1)))
+			default:
+				op = "turn"
+				g.PlayTurn()
+			}
+
+			want := scanFree(&g)
+			if got := indexFree(&g); !reflect.DeepEqual(got, want) {
+				t.Fatalf("seed %v, %v after %v: index %v, scan %v", seed, i, op, got, want)
+			}
+
+			if g.Free() != len(want) {
+				t.Fatalf("seed %v, %v after %v: Free=%v, scan %v", seed, i, op, g.Free(), len(want))
+			}
+		}
+	}
+}
+
+func TestPlayTurnMatchesScan(t *testing.T) {
+	for _, seed := range []int64{1, 2, 3, 4, 5} {
+		var g Game
+		g.SetupSeed(20, 15, 1, 1, seed)
+		c := g.Clone()
+
+		for turn := 0; turn < 100 && !g.Winner(); turn++ {
+			g.PlayTurn()
+			scanPlayTurn(c)
+
+			if !reflect.DeepEqual(g.Screen, c.Screen) {
+				t.Fatalf("seed %v turn %v: the index and the scan removed different arrows", seed, turn)
+			}
+
+			if g.Free() == 0 {
+				g.Shuffle(ShuffleRandom)
+				c.Screen = copyScreen(g.Screen)
+			}
+		}
+	}
+}
+
+var benchSizes = []int{20, 200, 1000}
+
+// a new board for each size (the large ones are slow to generate)
+var benchGames = map[int]*Game{}
+
+func benchGame(size int) *Game {
+	if benchGames[size] == nil {
+		var g Game
+		g.SetupSeed(size, size, 1, 1, 42)
+		benchGames[size] = &g
+	}
+
+	return benchGames[size]
+}
+
+func BenchmarkPlayTurn(b *testing.B) {
+	for _, size := range benchSizes {
+		for _, bm := range []struct {
+			name string
+			turn func(g *Game) Updates
+		}{
+			{"index", (*Game).PlayTurn},
+			{"scan", scanPlayTurn},
+		} {
+			b.Run(fmt.Sprintf("%vx%v/%v", size, size, bm.name), func(b *testing.B) {
+				g := benchGame(size)
+				b.ResetTimer()
+
+				for i := 0; i < b.N; i++ {
+					b.StopTimer()
+					c := g.Clone()
+					b.StartTimer()
+
+					bm.turn(c)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkFree(b *testing.B) {
+	for _, size := range benchSizes {
+		for _, bm := range []struct {
+			name string
+			free func(g *Game) int
+		}{
+			{"index", (*Game).Free},
+			{"scan", func(g *Game) int { return len(scanFree(g)) }},
+			{"next", func(g *Game) int {
+				x, _, _ := g.NextFree(g.Width/2, g.Height/2, false)
+				return x
+			}},
+		} {
+			b.Run(fmt.Sprintf("%vx%v/%v", size, size, bm.name), func(b *testing.B) {
+				g := benchGame(size).Clone()
+				g.Free()
+
+				// the game is played between the calls: remove a free arrow and undo it
+				free := scanFree(g)
+				b.ResetTimer()
+
+				for i := 0; i < b.N; i++ {
+					c := free[i%len(free)]
+					g.Update(c.X, c.Y, Remove)
+					bm.free(g)
+					g.Undo()
+				}
+			})
+		}
+	}
+}
@@ -1,7 +1,11 @@
 package main
 
 import (
+	"container/heap"
+	"fmt"
 	"math/rand"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -28,8 +32,32 @@ const (
 	// these is actually only used for playing sound effects
 	Shuffle = Updates(-1)
 	Undo    = Updates(-2)
+	Win     = Updates(-3)
 )
 
+// Shuffle modes
+type ShuffleMode int8
+
+const (
+	ShuffleRandom  = ShuffleMode(0) // a new random direction for all arrows
+	ShuffleLeft    = ShuffleMode(1) // rotate all arrows left
+	ShuffleRight   = ShuffleMode(2) // rotate all arrows right
+	ShuffleFlip    = ShuffleMode(3) // reverse all arrows
+	ShuffleBlocked = ShuffleMode(4) // a new random direction for the arrows that cannot move out
+	ShuffleMinimal = ShuffleMode(5) // turn a single arrow, so that it can move out
+)
+
+var shuffleModes = map[string]ShuffleMode{
+	"random":  ShuffleRandom,
+	"left":    ShuffleLeft,
+	"l":       ShuffleLeft,
+	"right":   ShuffleRight,
+	"r":       ShuffleRight,
+	"flip":    ShuffleFlip,
+	"blocked": ShuffleBlocked,
+	"minimal": ShuffleMinimal,
+}
+
 type Cell struct {
 	X int
 	Y int
@@ -42,6 +70,95 @@ type CellMoves struct {
 	Removed bool
 }
 
+//
+// board generation parameters
+//
+type BoardParams struct {
+	Density float64       `json:"density"` // fraction of the cells with an arrow
+	Weights [DirCount]int `json:"weights"` // relative frequency of Up, Down, Left and Right arrows
+	Cluster float64       `json:"cluster"` // probability that an arrow continues the run of its neighbour
+}
+
+// the default parameters generate the same boards as before they were introduced
+var DefaultParams = BoardParams{Density: 1, Weights: [DirCount]int{1, 1, 1, 1}}
+
+//
+// return the board parameters, checking they are valid
+// (weights is a list of comma separated weights for Up, Down, Left and Right)
+//
+func NewBoardParams(density float64, weights string, cluster float64) (p BoardParams, err error) {
+	if density <= 0 || density > 1 {
+		return p, fmt.Errorf("invalid density %v (should be more than 0, up to 1)", density)
+	}
+
+	if cluster < 0 || cluster >= 1 {
+		return p, fmt.Errorf("invalid cluster %v (should be at least 0, less than 1)", cluster)
+	}
+
+	ws := strings.Split(weights, ",")
+	if len(ws) != DirCount {
+		return p, fmt.Errorf("invalid weights %q (should be 4 values, for up,down,left,right)", weights)
+	}
+
+	dirs := 0
+
+	for i, w := range ws {
+		n, err := strconv.Atoi(strings.TrimSpace(w))
+		if err != nil || n < 0 {
+			return p, fmt.Errorf("invalid weight %q", w)
+		}
+
+		if n > 0 {
+			dirs++
+		}
+
+		p.Weights[i] = n
+	}
+
+	if dirs < 2 { // shuffles need at least 2 directions
+		return p, fmt.Errorf("invalid weights %q (at least 2 directions should be used)", weights)
+	}
+
+	p.Density = density
+	p.Cluster = cluster
+	return p, nil
+}
+
+//
+// return a string describing the parameters, for the score key ("" for the default parameters)
+//
+func (p BoardParams) Key() string {
+	if p == DefaultParams {
+		return ""
+	}
+
+	return fmt.Sprintf("density=%v,weights=%v:%v:%v:%v,cluster=%v",
+		p.Density, p.Weights[0], p.Weights[1], p.Weights[2], p.Weights[3], p.Cluster)
+}
+
+//
+// return a random direction, according to the weights
+// (with the default weights this is the same as rng.Intn(DirCount) + 1)
+//
+func (p BoardParams) randomDir(rng *rand.Rand) Dir {
+	total := 0
+	for _, w := range p.Weights {
+		total += w
+	}
+
+	n := rng.Intn(total)
+
+	for i, w := range p.Weights {
+		if n < w {
+			return Dir(i + 1) // 0 is Empty
+		}
+
+		n -= w
+	}
+
+	return Up
+}
+
 type Game struct {
 	Screen     [][]Dir
 	Width      int
@@ -53,18 +170,77 @@ type Game struct {
 	MaxSeq     int
 	Score      int
 	FinalScore int
+	Shuffles   int
+	Hints      int
+	Blocked    int  // clicks on blocked arrows
+	Autoplay   bool // autoplay was used
+	Scored     bool // the score was recorded
 	Completed  bool
+	Over       bool // the game ended with arrows left (hardcore mode)
+	Hardcore   bool // the game was played in hardcore mode
+	Seed       int64
+	Params     BoardParams
+	Difficulty Difficulty
+	Draws      int64 `json:",omitempty"` // random numbers used so far (only updated when the game is saved)
+
+	// arrows removed and shuffles already added to the statistics
+	// (when the game was abandoned, and saved to be resumed)
+	CountedRemoved  int `json:",omitempty"`
+	CountedShuffles int `json:",omitempty"`
 
 	cellwidth  int
 	cellheight int
 
+	rng   *rand.Rand
+	src   *countingSource
 	stack []*CellMoves
+	free  *freeIndex // index of the free arrows (built when needed)
+}
+
+//
+// a random source that counts the numbers it generated,
+// so that a saved game can restore it (generating the same numbers again)
+//
+type countingSource struct {
+	rand.Source64
+	n int64
+}
+
+func newCountingSource(seed int64, skip int64) *countingSource {
+	s := &countingSource{Source64: rand.NewSource(seed).(rand.Source64)}
+	for s.n < skip {
+		s.Int63()
+	}
+
+	return s
+}
+
+func (s *countingSource) Int63() int64 {
+	s.n++
+	return s.Source64.Int63()
+}
+
+func (s *countingSource) Uint64() uint64 {
+	s.n++
+	return s.Source64.Uint64()
+}
+
+//
+// set the random generator, skipping the first n numbers
+//
+func (g *Game) seedRandom(seed, n int64) {
+	g.src = newCountingSource(seed, n)
+	g.rng = rand.New(g.src)
 }
 
 func (g *Game) Push(count int, removed bool, moves []Cell) {
 	g.stack = append(g.stack, &CellMoves{Cells: moves, Count: count, Removed: removed})
 }
 
+func (g *Game) StackSize() int {
+	return len(g.stack)
+}
+
 func (g *Game) Pop() (cm *CellMoves) {
 	l := len(g.stack)
 
@@ -77,9 +253,47 @@ func (g *Game) Pop() (cm *CellMoves) {
 }
 
 //
-// setup game
+// return a copy of the game, that can be played independently
+//
+func (g *Game) Clone() *Game {
+	c := *g
+	c.Screen = make([][]Dir, len(g.Screen))
+	for y, row := range g.Screen {
+		c.Screen[y] = append([]Dir(nil), row...)
+	}
+
+	c.stack = append([]*CellMoves(nil), g.stack...)
+	c.free = nil
+
+	// the clone gets its own random generator, seeded without using the original one
+	// (so that cloning a game doesn't change how it plays)
+	c.seedRandom(g.Seed+int64(g.Shuffles), 0)
+	return &c
+}
+
+//
+// setup game and rate its difficulty
+// (generating new boards until one is at the requested difficulty)
 //
 func (g *Game) Setup(w, h, cw, ch int) {
+	g.SetupSeed(w, h, cw, ch, gameSeed)
+
+	if gameDifficulty == "" || seedRated { // any board, or the board chosen by the race host
+		g.Difficulty = g.Rate()
+	} else {
+		g.regenerate(gameDifficulty)
+	}
+}
+
+//
+// setup game using the specified seed
+// (a 0 seed generates a random board)
+//
+func (g *Game) SetupSeed(w, h, cw, ch int, seed int64) {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
 	g.Screen = nil
 	g.Width = w
 	g.Height = h
@@ -90,23 +304,56 @@ func (g *Game) Setup(w, h, cw, ch int) {
 	g.MaxSeq = 0
 	g.Score = 0
 	g.FinalScore = 0
+	g.Shuffles = 0
+	g.Hints = 0
+	g.Blocked = 0
+	g.Autoplay = false
+	g.Scored = false
 	g.Completed = false
+	g.Over = false
+	g.Hardcore = scoreRules.Hardcore
+	g.Seed = seed
+	g.Params = boardParams
+	g.Difficulty = Difficulty{}
+	g.CountedRemoved = 0
+	g.CountedShuffles = 0
 
 	g.cellwidth = cw
 	g.cellheight = ch
 	g.stack = g.stack[:0]
 
-	rand.Seed(time.Now().Unix())
+	g.seedRandom(seed, 0)
 
 	for i := 0; i < g.Height; i++ {
 		var line []Dir
 
 		for j := 0; j < g.Width; j++ {
-			cell := Dir(rand.Intn(DirCount) + 1) // 0 is Empty
+			cell := g.Params.randomDir(g.rng)
 
 			if i == 0 || i == g.Height-1 || j == 0 || j == g.Width-1 {
 				// empty cell at the border, to make it easier to check if we can move
 				cell = Empty
+				line = append(line, cell)
+				continue
+			}
+
+			if g.Params.Cluster > 0 && g.rng.Float64() < g.Params.Cluster {
+				// continue the run of the arrow on the left (or above) in the same direction
+				switch cell {
+				case Left, Right:
+					if left := line[j-1]; left == Left || left == Right {
+						cell = left
+					}
+
+				case Up, Down:
+					if up := g.Screen[i-1][j]; up == Up || up == Down {
+						cell = up
+					}
+				}
+			}
+
+			if g.Params.Density < 1 && g.rng.Float64() >= g.Params.Density {
+				cell = Empty // leave a hole
 			} else {
 				g.Count++
 			}
@@ -118,23 +365,37 @@ func (g *Game) Setup(w, h, cw, ch int) {
 	}
 
 	g.simplify()
+	g.free = nil
 }
 
 //
 // shuffle arrows
 // (actually replace/rotate arrows where present)
 //
-func (g *Game) Shuffle(dir Dir) {
+func (g *Game) Shuffle(mode ShuffleMode) {
+	var free []bool // the free arrows (that are not changed by ShuffleBlocked)
+	var changed []Cell
+
+	switch mode {
+	case ShuffleBlocked:
+		free = make([]bool, g.Width*g.Height)
+		g.index().each(func(x, y int) { free[y*g.Width+x] = true })
+
+	case ShuffleMinimal:
+		g.turnOne()
+	}
+
 	g.Count = 0
 	g.Seq = 0
+	g.Shuffles++
 
 	for y, row := range g.Screen {
 		for x, col := range row {
 			if col != Empty {
 				g.Count++
 
-				switch dir {
-				case Up, Left:
+				switch mode {
+				case ShuffleLeft:
 					// rotate left
 					switch g.Screen[y][x] {
 					case Up:
@@ -147,7 +408,7 @@ func (g *Game) Shuffle(dir Dir) {
 						g.Screen[y][x] = Up
 					}
 
-				case Down, Right:
+				case ShuffleRight:
 					// rotate right
 					switch g.Screen[y][x] {
 					case Up:
@@ -160,65 +421,114 @@ func (g *Game) Shuffle(dir Dir) {
 						g.Screen[y][x] = Down
 					}
 
-				default:
-					// random shuffle
-					var newdir Dir
-					for newdir = g.Screen[y][x]; newdir == g.Screen[y][x]; newdir = Dir(rand.Intn(DirCount) + 1) { // 0 is Empty
-						// try again
+				case ShuffleFlip:
+					g.Screen[y][x] = opposite[col]
+
+				case ShuffleMinimal:
+					// already changed
+
+				case ShuffleBlocked:
+					if !free[y*g.Width+x] {
+						g.Screen[y][x] = g.rerollDir(col)
+						changed = append(changed, Cell{X: x, Y: y})
 					}
 
-					g.Screen[y][x] = newdir
+				default:
+					// random shuffle
+					g.Screen[y][x] = g.rerollDir(col)
 				}
 			}
 		}
 	}
 
-	if dir == Empty {
+	switch mode {
+	case ShuffleRandom:
 		g.simplify()
+
+	case ShuffleBlocked:
+		for _, c := range changed {
+			g.simplifyCell(c.X, c.Y)
+		}
 	}
 
 	g.stack = g.stack[:0]
+	g.free = nil
 }
 
-func (g *Game) simplify() {
-	opposite := map[Dir]Dir{
-		Up:    Down,
-		Down:  Up,
-		Left:  Right,
-		Right: Left,
+// return a random direction, different from the current one
+func (g *Game) rerollDir(cur Dir) Dir {
+	var newdir Dir
+	for newdir = cur; newdir == cur; newdir = g.Params.randomDir(g.rng) {
+		// try again
 	}
 
-	for y, row := range g.Screen {
-		for x, col := range row {
-			if col == Empty {
-				continue
-			}
+	return newdir
+}
 
-			for c := 0; c < DirCount; c++ {
-				opp := opposite[col]
+//
+// turn a single blocked arrow, at the start or at the end of a row or column, towards the border
+// (the fewest changes needed to create a free move)
+//
+func (g *Game) turnOne() {
+	var cells []Cell
 
-				if g.Screen[y][x-1] == opp || g.Screen[y][x+1] == opp || g.Screen[y-1][x] == opp || g.Screen[y+1][x] == opp {
-					switch col {
-					case Up:
-						col = Left
-					case Down:
-						col = Right
-					case Left:
-						col = Down
-					case Right:
-						col = Up
-					}
+	g.index().ends(g, func(x, y int, d Dir) {
+		if g.Screen[y][x] != d {
+			cells = append(cells, Cell{X: x, Y: y, D: d})
+		}
+	})
 
-					continue
-				}
+	if len(cells) > 0 {
+		c := cells[g.rng.Intn(len(cells))]
+		g.set(c.X, c.Y, c.D)
+	}
+}
+
+var opposite = map[Dir]Dir{
+	Up:    Down,
+	Down:  Up,
+	Left:  Right,
+	Right: Left,
+}
 
-				break
+//
+// change the arrows that point to each other (they would block each other)
+//
+func (g *Game) simplify() {
+	for y, row := range g.Screen {
+		for x, col := range row {
+			if col != Empty {
+				g.simplifyCell(x, y)
 			}
+		}
+	}
+}
 
-			g.Screen[y][x] = col
+func (g *Game) simplifyCell(x, y int) {
+	col := g.Screen[y][x]
+
+	for c := 0; c < DirCount; c++ {
+		opp := opposite[col]
+
+		if g.Screen[y][x-1] == opp || g.Screen[y][x+1] == opp || g.Screen[y-1][x] == opp || g.Screen[y+1][x] == opp {
+			switch col {
+			case Up:
+				col = Left
+			case Down:
+				col = Right
+			case Left:
+				col = Down
+			case Right:
+				col = Up
+			}
 
+			continue
 		}
+
+		break
 	}
+
+	g.Screen[y][x] = col
 }
 
 //
@@ -255,6 +565,99 @@ func (g *Game) Peek(x, y int) (int, int, Dir) {
 	return -1, -1, InvalidDir
 }
 
+//
+// find the arrows that would move starting from game coordinates cx,cy
+// (the arrows in the same direction, in front of cx,cy)
+// and the empty cells in front of them.
+//
+// removing: the arrows can leave the board
+// ok: the arrows can move (at least one empty cell in front of them)
+//
+func (g *Game) path(cx, cy int) (curdir Dir, cells, empty []Cell, removing, ok bool) {
+	curdir = g.Screen[cy][cx]
+
+	dx, dy := 0, 0
+
+	switch curdir {
+	case Up:
+		dy = -1
+	case Down:
+		dy = 1
+	case Left:
+		dx = -1
+	case Right:
+		dx = 1
+	default:
+		return
+	}
+
+	inside := func(x, y int) bool {
+		return x >= 0 && x <= g.Width-1 && y >= 0 && y <= g.Height-1
+	}
+
+	x, y := cx, cy
+
+	for ; x > 0 && x < g.Width-1 && y > 0 && y < g.Height-1 && g.Screen[y][x] == curdir; x, y = x+dx, y+dy {
+		cells = append(cells, Cell{X: x, Y: y, D: curdir})
+	}
+
+	px, py := x, y
+
+	for ; inside(x, y) && g.Screen[y][x] == Empty; x, y = x+dx, y+dy {
+		px, py = x, y
+		empty = append(empty, Cell{X: x, Y: y, D: Empty})
+	}
+
+	if g.Screen[py][px] != Empty {
+		return
+	}
+
+	removing = px == 0 || px == g.Width-1 || py == 0 || py == g.Height-1
+	ok = true
+	return
+}
+
+//
+// check if the arrow at game coordinates x,y can leave the board
+// (the path is made only of arrows in the same direction followed by empty cells)
+//
+func (g *Game) IsFree(x, y int) bool {
+	_, _, _, removing, ok := g.path(x, y)
+	return ok && removing
+}
+
+//
+// preview what Update would do at screen coordinates x,y, without changing the game:
+// returns the arrows that would move, the empty cells they would go through and the result
+// (Remove, Move, None or Invalid)
+//
+func (g *Game) Preview(x, y int) (cells, empty []Cell, res Updates) {
+	cx, cy, ok := g.Coords(x, y)
+	if !ok {
+		return nil, nil, Invalid
+	}
+
+	_, cells, empty, removing, ok := g.path(cx, cy)
+
+	switch {
+	case !ok:
+		return cells, nil, None
+
+	case removing:
+		return cells, empty, Remove
+
+	default:
+		return cells, empty, Move
+	}
+}
+
+//
+// return the number of arrows that can currently leave the board
+//
+func (g *Game) Free() int {
+	return g.index().count
+}
+
 //
 // update game based on screen coordinates
 // returns game coordinates (and false if outside of boundaries)
@@ -266,18 +669,16 @@ func (g *Game) Update(x, y int, op Updates) (cx, cy int, res Updates) {
 	var ok bool
 
 	cx, cy, ok = g.Coords(x, y)
-	if !ok {
+	if !ok || g.Over {
 		return -1, -1, Invalid
 	}
 
 	res = None
 
 	if op > None {
-		var px, py int
+		var curdir Dir
 		var cells, empty []Cell
 
-		curdir := g.Screen[cy][cx]
-
 		update := func(removing bool) (ret Updates) {
 			lc := len(cells)
 			le := len(empty)
@@ -310,14 +711,14 @@ func (g *Game) Update(x, y int, op Updates) (cx, cy int, res Updates) {
 			}
 
 			for _, c := range cells[:lc] {
-				g.Screen[c.Y][c.X] = Empty // remove from old position
+				g.set(c.X, c.Y, Empty) // remove from old position
 			}
 
 			if le > 0 {
 				cells = append(cells, empty...)
 
 				for _, c := range cells[len(cells)-lc:] {
-					g.Screen[c.Y][c.X] = curdir // move into new position
+					g.set(c.X, c.Y, curdir) // move into new position
 				}
 			}
 
@@ -328,71 +729,18 @@ func (g *Game) Update(x, y int, op Updates) (cx, cy int, res Updates) {
 			return
 		}
 
-		switch curdir {
-		case Up:
-			for py = cy; py > 0 && g.Screen[py][cx] == curdir; py-- {
-				cells = append(cells, Cell{X: cx, Y: py, D: curdir})
-			}
-
-			for ppy := py; ppy >= 0 && g.Screen[ppy][cx] == Empty; ppy-- {
-				py = ppy
-				empty = append(empty, Cell{X: cx, Y: py, D: Empty})
-			}
-
-			if g.Screen[py][cx] != Empty {
-				return
-			}
-
-			res = update(py == 0)
-
-		case Down:
-			for py = cy; py < g.Height-1 && g.Screen[py][cx] == curdir; py++ {
-				cells = append(cells, Cell{X: cx, Y: py, D: curdir})
-			}
-
-			for ppy := py; ppy <= g.Height-1 && g.Screen[ppy][cx] == Empty; ppy++ {
-				py = ppy
-				empty = append(empty, Cell{X: cx, Y: py, D: Empty})
-			}
-
-			if g.Screen[py][cx] != Empty {
-				return
-			}
-
-			res = update(py == g.Height-1)
-
-		case Left:
-			for px = cx; px > 0 && g.Screen[cy][px] == curdir; px-- {
-				cells = append(cells, Cell{X: px, Y: cy, D: curdir})
-			}
-
-			for ppx := px; ppx >= 0 && g.Screen[cy][ppx] == Empty; ppx-- {
-				px = ppx
-				empty = append(empty, Cell{X: px, Y: cy, D: Empty})
-			}
-
-			if g.Screen[cy][px] != Empty {
-				return
-			}
+		var removing bool
 
-			res = update(px == 0)
-
-		case Right:
-			for px = cx; px < g.Width-1 && g.Screen[cy][px] == curdir; px++ {
-				cells = append(cells, Cell{X: px, Y: cy, D: curdir})
-			}
-
-			for ppx := px; ppx <= g.Width-1 && g.Screen[cy][ppx] == Empty; ppx++ {
-				px = ppx
-				empty = append(empty, Cell{X: px, Y: cy, D: Empty})
-			}
-
-			if g.Screen[cy][px] != Empty {
-				return
+		curdir, cells, empty, removing, ok = g.path(cx, cy)
+		if !ok {
+			if curdir != Empty && !g.Completed && g.Hardcore {
+				g.Blocked++ // the arrow cannot move (only penalized in hardcore mode)
 			}
 
-			res = update(px == g.Width-1)
+			return
 		}
+
+		res = update(removing)
 	}
 
 	return
@@ -402,7 +750,7 @@ func (g *Game) Undo() (cx, cy int, ok bool) {
 	if cm := g.Pop(); cm != nil {
 		for _, m := range cm.Cells {
 			cx, cy = m.X, m.Y
-			g.Screen[m.Y][m.X] = m.D
+			g.set(m.X, m.Y, m.D)
 		}
 
 		if !g.Completed {
@@ -429,6 +777,77 @@ func (g *Game) Undo() (cx, cy int, ok bool) {
 	return -1, -1, false
 }
 
+//
+// return the first free arrow after x,y (or before it, if back is true) in reading order,
+// wrapping around the board (ok is false if there are no free arrows)
+//
+func (g *Game) NextFree(x, y int, back bool) (nx, ny int, ok bool) {
+	p := y*g.Width + x
+	n := g.Width * g.Height
+	dist := n + 1
+
+	g.index().each(func(fx, fy int) {
+		d := fy*g.Width + fx - p
+		if back {
+			d = -d
+		}
+
+		if d <= 0 {
+			d += n // wrap around
+		}
+
+		if d < dist {
+			dist, nx, ny, ok = d, fx, fy, true
+		}
+	})
+
+	return
+}
+
+//
+// remove all "free" arrows
+// returns the "best" update (Remove if any arrow was removed)
+//
+// The cells are visited in order (top to bottom, left to right) and an arrow freed by a removal
+// is also removed in the same turn, if it comes later. Only the free arrows are visited,
+// using the index, so a turn costs in proportion to the arrows removed.
+//
+func (g *Game) PlayTurn() Updates {
+	if g.Width <= 2 || g.Height <= 2 {
+		return Invalid
+	}
+
+	moved := None
+	cur := -1
+
+	var cells cellHeap
+
+	push := func(x, y int) {
+		if p := y*g.Width + x; p > cur {
+			heap.Push(&cells, p)
+		}
+	}
+
+	g.index().each(push)
+
+	for cells.Len() > 0 {
+		p := heap.Pop(&cells).(int)
+		if p <= cur {
+			continue // already visited
+		}
+
+		cur = p
+
+		x, y := g.ScreenCoords(0, 0, p%g.Width, p/g.Width)
+		if _, _, mov := g.Update(x, y, Remove); mov == Remove {
+			moved = Remove
+			g.free.update(g, push)
+		}
+	}
+
+	return moved
+}
+
 var WinBanner = [][]Dir{
 	{Down, Down, Up, Up, Down, Down, Up, Up, Down, Down, Down, Down, Up, Up, Down, Down, Up, Up, Down, Down},
 	{Up, Up, Up, Up, Up, Up, Up, Down, Up, Up, Up, Up, Down, Up, Up, Up, Up, Up, Up, Up},
@@ -463,7 +882,7 @@ func (g *Game) Winner() bool {
 
 	for y, row := range WinBanner {
 		for x, col := range row {
-			g.Screen[hw+y][ww+x] = col
+			g.set(ww+x, hw+y, col)
 			if col != Empty {
 				g.Count++
 			}
@@ -474,22 +893,235 @@ func (g *Game) Winner() bool {
 }
 
 type ScoreInfo struct {
-	Moves  int
-	MaxSeq int
-	Score  int
+	Moves      int
+	MaxSeq     int
+	Score      int
+	Difficulty string `json:",omitempty"` // difficulty level of the board
+	Seed       int64  `json:",omitempty"` // board seed
+	Time       int64  `json:",omitempty"` // when the game was completed (unix time in nanoseconds)
+}
+
+//
+// return true if the two entries are the same game
+// (entries saved before the seed and time were recorded can only be compared as a whole)
+//
+func (si ScoreInfo) Same(other ScoreInfo) bool {
+	if si.Time == 0 && other.Time == 0 {
+		return si == other
+	}
+
+	return si.Seed == other.Seed && si.Time == other.Time
+}
+
+type Scores map[string][]ScoreInfo
+
+//
+// scores with different rules (or board parameters) are kept in different scoreboards
+//
+func scoreKey(w, h int) string {
+	key := strconv.Itoa(w*1000 + h)
+	if scoreRules.Name != ClassicRules.Name {
+		key += "/" + scoreRules.Name
+	}
+
+	if scoreRules.Hardcore {
+		key += "/hardcore"
+	}
+
+	if scoreRules.ShuffleBudget > 0 {
+		key += "/shuffles=" + strconv.Itoa(scoreRules.ShuffleBudget)
+	}
+
+	if params := boardParams.Key(); params != "" {
+		key += "/" + params
+	}
+
+	return key
+}
+
+//
+// scoring rules
+//
+type ScoreRules struct {
+	Name           string
+	SeqPoints      int // points for each removed arrow, multiplied by the sequence length
+	EfficiencyDiv  int // efficiency bonus is n*n/EfficiencyDiv, with n = removed - moves (0: no bonus)
+	ShufflePenalty int // points lost for each shuffle
+	HintPenalty    int // points lost for each hint
+	BlockedPenalty int // points lost for each click on a blocked arrow
+	ShuffleBudget  int // shuffles allowed in a game (0: no limit)
+	Hardcore       bool
+}
+
+const (
+	budgetPenalty = 25 // minimum points lost for each shuffle, when the shuffles are limited
+
+	hardcorePenalty  = 10 // points lost for each click on a blocked arrow, in hardcore mode
+	hardcoreShuffles = 3  // shuffles allowed in hardcore mode, if not specified
+)
+
+var (
+	ClassicRules = ScoreRules{Name: "classic", SeqPoints: 1, EfficiencyDiv: 2}
+	FairRules    = ScoreRules{Name: "fair", SeqPoints: 1, EfficiencyDiv: 2, ShufflePenalty: 10, HintPenalty: 20}
+	StrictRules  = ScoreRules{Name: "strict", SeqPoints: 1, EfficiencyDiv: 4, ShufflePenalty: 50, HintPenalty: 100}
+
+	ScorePresets = map[string]ScoreRules{
+		ClassicRules.Name: ClassicRules,
+		FairRules.Name:    FairRules,
+		StrictRules.Name:  StrictRules,
+	}
+)
+
+//
+// return the rules with a limited number of shuffles for each game
+// (and a penalty of at least budgetPenalty points for each shuffle)
+//
+func (r ScoreRules) WithBudget(shuffles int) ScoreRules {
+	r.ShuffleBudget = shuffles
+
+	if r.ShufflePenalty < budgetPenalty {
+		r.ShufflePenalty = budgetPenalty
+	}
+
+	return r
+}
+
+//
+// return the rules for the hardcore mode: no undo, no hints and no autoplay,
+// clicks on blocked arrows cost points and the shuffles are limited
+//
+func (r ScoreRules) WithHardcore() ScoreRules {
+	r.Hardcore = true
+	r.BlockedPenalty = hardcorePenalty
+
+	if r.ShuffleBudget == 0 {
+		r = r.WithBudget(hardcoreShuffles)
+	}
+
+	return r
+}
+
+//
+// return the number of shuffles left in the game (-1 if there is no limit)
+//
+func (g *Game) ShufflesLeft() int {
+	if scoreRules.ShuffleBudget == 0 {
+		return -1
+	}
+
+	if left := scoreRules.ShuffleBudget - g.Shuffles; left > 0 {
+		return left
+	}
+
+	return 0
+}
+
+//
+// shuffle the arrows, if there are shuffles left
+// (returns false, without shuffling, when the shuffle budget is used up)
+//
+func (g *Game) PlayShuffle(mode ShuffleMode) bool {
+	if g.ShufflesLeft() == 0 {
+		return false
+	}
+
+	g.Shuffle(mode)
+	return true
+}
+
+//
+// in hardcore mode the game is over when there are no free arrows and no shuffles left
+//
+func (g *Game) Stuck() bool {
+	return scoreRules.Hardcore && !g.Completed && g.Count > 0 && g.ShufflesLeft() == 0 && g.Free() == 0
+}
+
+//
+// how the final score was computed
+//
+type ScoreBreakdown struct {
+	Rules          string
+	Sequence       int // sequence points
+	Removed        int
+	Moves          int
+	Efficiency     int // efficiency bonus
+	Shuffles       int
+	ShuffleBudget  int
+	ShufflePenalty int
+	Hints          int
+	HintPenalty    int
+	Blocked        int
+	BlockedPenalty int
+	Total          int
+}
+
+func (g *Game) Breakdown(r ScoreRules) (b ScoreBreakdown) {
+	b.Rules = r.Name
+	b.Sequence = g.Score * r.SeqPoints
+	b.Removed = g.Removed
+	b.Moves = g.Moves
+
+	if r.EfficiencyDiv > 0 {
+		n := g.Removed - g.Moves
+		b.Efficiency = n * n / r.EfficiencyDiv
+	}
+
+	b.Shuffles = g.Shuffles
+	b.ShuffleBudget = r.ShuffleBudget
+	b.ShufflePenalty = g.Shuffles * r.ShufflePenalty
+	b.Hints = g.Hints
+	b.HintPenalty = g.Hints * r.HintPenalty
+	b.Blocked = g.Blocked
+	b.BlockedPenalty = g.Blocked * r.BlockedPenalty
+
+	b.Total = b.Sequence + b.Efficiency - b.ShufflePenalty - b.HintPenalty - b.BlockedPenalty
+	if b.Total < 0 {
+		b.Total = 0
+	}
+
+	return
 }
 
-type Scores map[int][]ScoreInfo
+//
+// return a description of the score, one line per item
+//
+func (b ScoreBreakdown) Lines() []string {
+	lines := []string{
+		fmt.Sprintf("sequence points:  %6d", b.Sequence),
+		fmt.Sprintf("efficiency bonus: %6d  (removed=%v moves=%v)", b.Efficiency, b.Removed, b.Moves),
+	}
+
+	if b.ShufflePenalty > 0 && b.ShuffleBudget > 0 {
+		lines = append(lines, fmt.Sprintf("shuffle penalty:  %6d  (shuffles=%v/%v)", -b.ShufflePenalty, b.Shuffles, b.ShuffleBudget))
+	} else if b.ShufflePenalty > 0 {
+		lines = append(lines, fmt.Sprintf("shuffle penalty:  %6d  (shuffles=%v)", -b.ShufflePenalty, b.Shuffles))
+	}
+
+	if b.HintPenalty > 0 {
+		lines = append(lines, fmt.Sprintf("hint penalty:     %6d  (hints=%v)", -b.HintPenalty, b.Hints))
+	}
+
+	if b.BlockedPenalty > 0 {
+		lines = append(lines, fmt.Sprintf("blocked penalty:  %6d  (blocked=%v)", -b.BlockedPenalty, b.Blocked))
+	}
+
+	return append(lines, fmt.Sprintf("final score:      %6d  (%v rules)", b.Total, b.Rules))
+}
 
-func scoreKey(w, h int) int {
-	return w*1000 + h
+//
+// compute final score (sequence points plus a bonus for removing groups of arrows,
+// minus penalties, according to the current rules)
+//
+func (g *Game) ComputeScore() int {
+	g.FinalScore = g.Breakdown(scoreRules).Total
+	return g.FinalScore
 }
 
 func (sc Scores) Update(g *Game) *ScoreInfo {
-	n := g.Removed - g.Moves
-	g.FinalScore = g.Score + (n * n / 2)
+	g.ComputeScore()
 
-	info := ScoreInfo{Moves: g.Moves, MaxSeq: g.MaxSeq, Score: g.FinalScore}
+	info := ScoreInfo{Moves: g.Moves, MaxSeq: g.MaxSeq, Score: g.FinalScore, Difficulty: g.Difficulty.Level,
+		Seed: g.Seed, Time: time.Now().UnixNano()}
 
 	key := scoreKey(g.Width, g.Height)
 	ss := sc[key]
@@ -1,64 +1,222 @@
 package main
 
 import (
-	"bytes"
 	"fmt"
 	"image"
 	"image/color"
 	"image/draw"
-	"image/png"
-	"log"
-
-	_ "embed"
+	"sort"
+	"strings"
 
 	"gioui.org/app"
+	"gioui.org/f32"
+	"gioui.org/font/gofont"
 	"gioui.org/io/key"
 	"gioui.org/io/pointer"
+	"gioui.org/io/semantic"
 	"gioui.org/io/system"
 	"gioui.org/layout"
 	"gioui.org/op"
 	"gioui.org/op/clip"
 	"gioui.org/op/paint"
 	"gioui.org/unit"
-	"gioui.org/widget"
+	"gioui.org/widget/material"
 
 	"github.com/disintegration/imaging"
 )
 
+// tag for the board key events
+type boardTag struct{}
+
 var (
-	//go:embed assets/up-arrow.png
-	pngUp []byte
+	canvas draw.Image
+	wopts  []app.Option
 
-	//go:embed assets/dot.png
-	pngDot []byte
+	theme      = material.NewTheme(gofont.Collection())
+	scoreLines []string // score breakdown, displayed at the end of the game
+	statsLines []string // lifetime statistics or scoreboard, displayed on request
+	gameStatus string   // the window title, also used to describe the board to screen readers
 
-	bgColor = color.NRGBA{0, 0, 32, 255}
+	zoomLevels = []float32{0.25, 0.5, 1, 2, 4}
 
-	gDirs [5]image.Image
-	gDot  image.Image
-	cell  image.Point
+	// shift+arrows pan the board
+	panDirs = map[string]image.Point{
+		key.NameUpArrow:    {0, -1},
+		key.NameDownArrow:  {0, 1},
+		key.NameLeftArrow:  {-1, 0},
+		key.NameRightArrow: {1, 0},
+	}
 
-	canvas draw.Image
-	wopts  []app.Option
+	// what screen readers say about a cell, and about the result of a move
+	dirNames = map[Dir]string{
+		Up:    "up",
+		Down:  "down",
+		Left:  "left",
+		Right: "right",
+	}
+
+	previewNames = map[Updates]string{
+		Remove: "free",
+		Move:   "can move",
+		None:   "blocked",
+	}
+
+	moveResults = map[Updates]string{
+		Invalid: "no arrow",
+		None:    "blocked",
+		Move:    "arrows moved",
+		Remove:  "arrows removed",
+	}
 )
 
+const (
+	maxWindowSize = 800 // maximum window width and height (dp), larger boards are scrolled
+	defaultZoom   = 2   // zoomLevels[defaultZoom] is 1 (one pixel per image pixel)
+	dragThreshold = 8   // pointer movement (pixels) that starts a drag instead of a click
+	minimapSize   = 160 // maximum minimap width and height (pixels)
+	minimapMargin = 8
+	hardcoreFrame = 4 // width of the hardcore mode frame (pixels)
+)
+
+// the part of the board displayed in the window
+type viewport struct {
+	size  image.Point // window size (pixels)
+	board image.Point // board size (image pixels)
+	pos   image.Point // board position (image pixels) at the top-left corner of the window
+	zoom  int         // index in zoomLevels
+
+	minimap image.Rectangle // where the minimap is drawn (empty if it isn't)
+}
+
+func (v *viewport) scale() float32 {
+	return zoomLevels[v.zoom]
+}
+
+// the size of the visible part of the board (image pixels)
+func (v *viewport) visible() image.Point {
+	return image.Pt(int(float32(v.size.X)/v.scale()), int(float32(v.size.Y)/v.scale()))
+}
+
+// return true if the whole board is visible
+func (v *viewport) all() bool {
+	vis := v.visible()
+	return vis.X >= v.board.X && vis.Y >= v.board.Y
+}
+
+// set the window size, choosing the zoom level the first time
+// (the largest level up to defaultZoom that shows the whole board, if any)
+func (v *viewport) resize(size image.Point) {
+	if v.size == (image.Point{}) {
+		v.size = size
+
+		for z := defaultZoom; z >= 0; z-- {
+			if v.zoom = z; v.all() {
+				break
+			}
+		}
+
+		if !v.all() {
+			v.zoom = defaultZoom
+		}
+	}
+
+	v.size = size
+	v.clamp()
+}
+
+// keep the view inside the board (the board is centered if it's smaller than the window)
+func (v *viewport) clamp() {
+	vis := v.visible()
+
+	clamp := func(pos, vis, board int) int {
+		switch {
+		case vis >= board:
+			return -(vis - board) / 2
+		case pos < 0:
+			return 0
+		case pos > board-vis:
+			return board - vis
+		}
+
+		return pos
+	}
+
+	v.pos.X = clamp(v.pos.X, vis.X, v.board.X)
+	v.pos.Y = clamp(v.pos.Y, vis.Y, v.board.Y)
+}
+
+// scroll the view by d (window pixels)
+func (v *viewport) scroll(d image.Point) {
+	v.pos.X += int(float32(d.X) / v.scale())
+	v.pos.Y += int(float32(d.Y) / v.scale())
+	v.clamp()
+}
+
+// change the zoom level, keeping the board point at p (window pixels) in place
+func (v *viewport) setZoom(zoom int, p image.Point) {
+	if zoom < 0 || zoom >= len(zoomLevels) {
+		return
+	}
+
+	bx, by := v.toBoard(f32.Pt(float32(p.X), float32(p.Y)))
+
+	v.zoom = zoom
+	v.pos.X = bx - int(float32(p.X)/v.scale())
+	v.pos.Y = by - int(float32(p.Y)/v.scale())
+	v.clamp()
+}
+
+// convert window coordinates to board coordinates (image pixels, as used by game.Update)
+func (v *viewport) toBoard(p f32.Point) (int, int) {
+	return v.pos.X + int(p.X/v.scale()), v.pos.Y + int(p.Y/v.scale())
+}
+
+// scroll the view so that cell x,y is visible
+func (v *viewport) show(x, y int) {
+	vis := v.visible()
+	r := image.Rect(x*cell.X, y*cell.Y, (x+1)*cell.X, (y+1)*cell.Y)
+
+	if r.Min.X < v.pos.X {
+		v.pos.X = r.Min.X
+	} else if r.Max.X > v.pos.X+vis.X {
+		v.pos.X = r.Max.X - vis.X
+	}
+
+	if r.Min.Y < v.pos.Y {
+		v.pos.Y = r.Min.Y
+	} else if r.Max.Y > v.pos.Y+vis.Y {
+		v.pos.Y = r.Max.Y - vis.Y
+	}
+
+	v.clamp()
+}
+
+// the cells that are (even partially) visible
+func (v *viewport) cells() image.Rectangle {
+	r := image.Rectangle{Min: v.pos, Max: v.pos.Add(v.visible())}.Intersect(image.Rectangle{Max: v.board})
+	return image.Rect(r.Min.X/cell.X, r.Min.Y/cell.Y, (r.Max.X+cell.X-1)/cell.X, (r.Max.Y+cell.Y-1)/cell.Y)
+}
+
 func setTitle(w *app.Window, title string) {
+	race.Report(&game)
+
 	if title == "" {
-		title = fmt.Sprintf("moves=%v remain=%v removed=%v seq=%v/%v score=%v",
-			game.Moves, game.Count, game.Removed, game.Seq, game.MaxSeq, game.Score)
+		title = statusLine()
+	} else if scoreRules.Hardcore {
+		title = "HARDCORE " + title
 	}
+	gameStatus = title
 	wopts[0] = app.Title(title)
 	w.Option(wopts...)
 }
 
 func updateScore(printed bool) bool {
 	if !printed {
-		if newscore := scores.Update(&game); newscore != nil {
-			fmt.Printf("New best score: moves=%v seq=%v score=%v\n",
-				newscore.Moves, newscore.MaxSeq, newscore.Score)
-		} else {
-			fmt.Printf("Score: moves=%v seq=%v score=%v\n",
-				game.Moves, game.MaxSeq, game.FinalScore)
+		fmt.Println(recordScore())
+
+		scoreLines = game.Breakdown(scoreRules).Lines()
+		for _, l := range scoreLines {
+			fmt.Println("  " + l)
 		}
 	}
 
@@ -66,27 +224,19 @@ func updateScore(printed bool) bool {
 }
 
 func gioGame(terminate func()) {
-	if img, err := png.Decode(bytes.NewBuffer(pngUp)); err != nil {
-		log.Fatal(err)
-	} else {
-		cell = img.Bounds().Size()
-
-		gDirs[Empty] = imaging.New(cell.X, cell.Y, bgColor)
-		gDirs[Up] = img
-		gDirs[Left] = imaging.Rotate90(gDirs[Up])
-		gDirs[Down] = imaging.Rotate90(gDirs[Left])
-		gDirs[Right] = imaging.Rotate90(gDirs[Down])
-	}
-
-	if img, err := png.Decode(bytes.NewBuffer(pngDot)); err != nil {
-		log.Fatal(err)
-	} else {
-		gDot = img
-	}
+	loadImages()
 
         ww := float32(gameWidth*cell.X) / 2
         wh := float32(gameHeight*cell.Y) / 2
 
+	// larger boards are displayed in a viewport that can be scrolled and zoomed
+	if ww > maxWindowSize {
+		ww = maxWindowSize
+	}
+	if wh > maxWindowSize {
+		wh = maxWindowSize
+	}
+
 	wopts = []app.Option{
 		app.Title("Arrows"), // title is first option
 		app.Size(unit.Dp(ww), unit.Dp(wh)),
@@ -96,33 +246,15 @@ func gioGame(terminate func()) {
 
 	go func() {
 		w := app.NewWindow(wopts...)
+		race.SetOnUpdate(w.Invalidate)
 		loop(w)
 		terminate()
 	}()
 	app.Main()
 }
 
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-
-	return b
-}
-
 func playturn(w *app.Window, title bool) (bool, bool) {
-	moved := Invalid
-
-	for y := 1; y < game.Height-1; y++ {
-		for x := 1; x < game.Width-1; x++ {
-			x, y := game.ScreenCoords(0, 0, x, y)
-			_, _, mov := game.Update(x, y, Remove)
-			if mov > moved {
-				moved = mov
-			}
-		}
-	}
-
+	moved := game.PlayTurn()
 	audioPlay(moved)
 
 	if title {
@@ -140,18 +272,35 @@ func playturn(w *app.Window, title bool) (bool, bool) {
 func loop(w *app.Window) {
 	var ops op.Ops
 
-	game.Setup(gameWidth, gameHeight, cell.X, cell.Y)
+	startGame(cell.X, cell.Y)
+
+	view := viewport{board: image.Pt(gameWidth*cell.X, gameHeight*cell.Y), zoom: defaultZoom}
 
-	gw := gameWidth * cell.X
-	gh := gameHeight * cell.Y
+	var dragStart, dragPos f32.Point
+	dragging := false
 
 	cx, cy := 1, 1
 
+	keys := boardKeys()
+	focused := false
+	outcome := "" // the result of the last action, for screen readers
+
 	gameover := false
 	autoplay := false
 	dotscreen := false
 	printscore := false
 
+	// in hardcore mode the game is over when there are no moves left
+	checkStuck := func() {
+		if game.Stuck() {
+			game.Over = true
+			gameover = true
+			printscore = updateScore(printscore)
+			setTitle(w, "No moves left")
+			w.Invalidate()
+		}
+	}
+
 	for e := range w.Events() {
 		switch e := e.(type) {
 		case system.DestroyEvent:
@@ -163,126 +312,240 @@ func loop(w *app.Window) {
 		case system.FrameEvent:
 			gtx := layout.NewContext(&ops, e)
 
-			if gw > e.Size.X || gh > e.Size.Y {
-				gw = min(gw, e.Size.X)
-				gh = min(gh, e.Size.Y)
-
-				gameWidth = gw / cell.X
-				gameHeight = gh / cell.Y
+			if race.Changed() && !dotscreen {
+				setTitle(w, "")
+			}
 
-				game.Setup(gameWidth, gameHeight, cell.X, cell.Y)
+			if e.Size != view.size {
+				view.resize(e.Size)
 			}
 
-			layout.Center.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
-				pressed := false
+			// Handle the keys (the board has the focus, and it gets the keys that no other handler wants)
+			for _, ev := range gtx.Events(boardTag{}) {
+				switch ev := ev.(type) {
+				case key.FocusEvent:
+					focused = ev.Focus
 
-				if gameover || autoplay {
-					if _, done := playturn(w, !gameover); done {
-						gameover = true
-						printscore = updateScore(printscore)
+				case key.Event:
+					if ev.State != key.Press {
+						break
+					}
 
-						if game.Winner() {
+					if d, ok := panDirs[ev.Name]; ok && ev.Modifiers.Contain(key.ModShift) {
+						view.scroll(image.Pt(d.X*view.size.X/2, d.Y*view.size.Y/2)) // pan by half a window
+						w.Invalidate()
+						break
+					}
+
+					switch ev.Name {
+					case key.NameUpArrow:
+						sx, sy := game.ScreenCoords(0, 0, cx, cy-1)
+						if _, _, dir := game.Peek(sx, sy); dir != InvalidDir {
+							cy--
+							outcome = ""
+							view.show(cx, cy)
+							w.Invalidate()
+						}
+
+					case key.NameDownArrow:
+						sx, sy := game.ScreenCoords(0, 0, cx, cy+1)
+						if _, _, dir := game.Peek(sx, sy); dir != InvalidDir {
+							cy++
+							outcome = ""
+							view.show(cx, cy)
+							w.Invalidate()
+						}
+
+					case key.NameLeftArrow:
+						sx, sy := game.ScreenCoords(0, 0, cx-1, cy)
+						if _, _, dir := game.Peek(sx, sy); dir != InvalidDir {
+							cx -= 1
+							outcome = ""
+							view.show(cx, cy)
+							w.Invalidate()
+						}
+
+					case key.NameRightArrow:
+						sx, sy := game.ScreenCoords(0, 0, cx+1, cy)
+						if _, _, dir := game.Peek(sx, sy); dir != InvalidDir {
+							cx += 1
+							outcome = ""
+							view.show(cx, cy)
+							w.Invalidate()
+						}
+
+					case key.NameSpace:
+						x, y := game.ScreenCoords(0, 0, cx, cy)
+						x, y, mov := game.Update(x, y, Move)
+						audioPlay(mov)
+						versus.Play(&game, x, y, mov)
+						outcome = moveResults[mov]
+
+						if mov != Invalid {
 							setTitle(w, "")
+						}
+
+						if game.Count == 0 {
+							gameover = true
+							printscore = updateScore(printscore)
+
+							if game.Winner() {
+								setTitle(w, "")
+							} else {
+								setTitle(w, "You Win!")
+								dotscreen = true
+							}
 						} else {
-							setTitle(w, "You Win!")
-							dotscreen = true
+							checkStuck()
 						}
-					} else if !gameover && autoplay {
+
+						w.Invalidate()
+					}
+
+					switch gioKeyAction(gioKeyName(ev)) {
+					case actionQuit:
+						return // w.Close()
+
+					case actionUndo:
+						if !undoAllowed() {
+							break
+						}
+
+						if _, _, ok := game.Undo(); ok {
+							audioPlay(Undo)
+							versus.Undo(&game)
+							setTitle(w, "")
+							outcome = "move undone"
+							w.Invalidate()
+						}
+					case actionReset:
+						audioPlay(Undo)
+						stats.Abandon(&game)
+						game.Setup(gameWidth, gameHeight, cell.X, cell.Y)
+						stats.Start(&game)
+						versus.Reset()
+						setTitle(w, "Arrows")
+						gameover = false
+						dotscreen = false
+						autoplay = false
+						printscore = false
+						scoreLines = nil
+						outcome = "new game"
+						w.Invalidate()
+
+					case actionShuffle:
+						if !game.PlayShuffle(shuffleMode) {
+							setTitle(w, "No shuffles left")
+							outcome = "no shuffles left"
+							break
+						}
+
 						audioPlay(Shuffle)
-						game.Shuffle(shuffleDir)
+						versus.Shuffle(&game)
 						setTitle(w, "")
-					}
-				} else {
-					// Handle any input from a pointer.
-					for _, ev := range gtx.Events(gDirs) {
-						if ev, ok := ev.(pointer.Event); ok {
-							if ev.Type == pointer.Press {
-								_, _, mov := game.Update(int(ev.Position.X), int(ev.Position.Y), Move)
-								audioPlay(mov)
-
-								if mov != Invalid {
-									setTitle(w, "")
-								}
-
-								if game.Count == 0 {
-									gameover = true
-									printscore = updateScore(printscore)
-
-									if game.Winner() {
-										setTitle(w, "")
-									} else {
-										setTitle(w, "You Win!")
-										dotscreen = true
-									}
-
-									w.Invalidate()
-								}
-
-								pressed = true
-							} else { // Move
-								x, y, dir := game.Peek(int(ev.Position.X), int(ev.Position.Y))
-								if dir != Empty {
-									cx, cy = x, y
-								}
-							}
+						outcome = "arrows shuffled"
+						checkStuck()
+						w.Invalidate()
+
+					case actionHint: // remove all "free" arrows
+						if !helpAllowed() { // no help in versus and hardcore mode
+							break
 						}
-					}
-				}
 
-				// Register to listen for pointer events.
-				pr := clip.Rect(image.Rectangle{Max: e.Size}).Push(gtx.Ops)
-				pointer.InputOp{Tag: gDirs, Types: pointer.Press | pointer.Move}.Add(gtx.Ops)
-				pr.Pop()
+						game.Hints++
+						var moved bool
+						moved, gameover = playturn(w, true)
+						outcome = map[bool]string{true: "free arrows removed", false: "no free arrows"}[moved]
+						if gameover {
+							printscore = updateScore(printscore)
+
+							if game.Winner() {
+								setTitle(w, "")
+							} else {
+								setTitle(w, "You Win!")
+								dotscreen = true
+							}
 
-				return render(gtx, gw, gh, cx, cy, pressed, dotscreen)
-			})
+							w.Invalidate()
+						}
 
-			e.Frame(gtx.Ops)
+					case actionAutoplay:
+						if autoplay { // stop autoplay
+							autoplay = false
+							setTitle(w, "Autoplay stopped")
+							break
+						}
 
-		case key.Event:
-			if e.State == key.Press {
-				switch e.Name {
-				case key.NameEscape, "Q", "X":
-					return // w.Close()
+						if !helpAllowed() {
+							break
+						}
 
-				case key.NameUpArrow:
-					sx, sy := game.ScreenCoords(0, 0, cx, cy-1)
-					if _, _, dir := game.Peek(sx, sy); dir != InvalidDir {
-						cy--
+						autoplay = true
+						game.Autoplay = true
 						w.Invalidate()
-					}
 
-				case key.NameDownArrow:
-					sx, sy := game.ScreenCoords(0, 0, cx, cy+1)
-					if _, _, dir := game.Peek(sx, sy); dir != InvalidDir {
-						cy++
+					case actionMute:
+						audioMute()
+						setTitle(w, audioStatus())
+
+					case actionVolumeDown:
+						audioChangeVolume(-0.5)
+						setTitle(w, audioStatus())
+
+					case actionVolumeUp:
+						audioChangeVolume(0.5)
+						setTitle(w, audioStatus())
+
+					case actionScoreboard:
+						if statsLines == nil {
+							statsLines = scoreboard(game.Width, game.Height)
+						} else {
+							statsLines = nil
+						}
+
 						w.Invalidate()
-					}
 
-				case key.NameLeftArrow:
-					sx, sy := game.ScreenCoords(0, 0, cx-1, cy)
-					if _, _, dir := game.Peek(sx, sy); dir != InvalidDir {
-						cx -= 1
+					case actionStats:
+						if statsLines == nil {
+							statsLines = stats.Lines(game.Width, game.Height)
+						} else {
+							statsLines = nil
+						}
+
 						w.Invalidate()
-					}
 
-				case key.NameRightArrow:
-					sx, sy := game.ScreenCoords(0, 0, cx+1, cy)
-					if _, _, dir := game.Peek(sx, sy); dir != InvalidDir {
-						cx += 1
+					case actionSnapshot:
+						filename := captureName(&game, "png")
+						if err := saveSnapshot(filename, game.Screen); err != nil {
+							setTitle(w, err.Error())
+						} else {
+							setTitle(w, "saved "+filename)
+						}
+
+					case actionZoomIn:
+						view.setZoom(view.zoom+1, view.size.Div(2))
 						w.Invalidate()
-					}
 
-				case key.NameSpace:
-					x, y := game.ScreenCoords(0, 0, cx, cy)
-					_, _, mov := game.Update(x, y, Move)
-					audioPlay(mov)
+					case actionZoomOut:
+						view.setZoom(view.zoom-1, view.size.Div(2))
+						w.Invalidate()
 
-					if mov != Invalid {
-						setTitle(w, "")
+					case actionReplay:
+						filename := captureName(&game, "gif")
+						if err := saveGIF(filename, replayFrames(&game)); err != nil {
+							setTitle(w, err.Error())
+						} else {
+							setTitle(w, "saved "+filename)
+						}
 					}
+				}
+			}
 
-					if game.Count == 0 {
+			layout.Center.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				pressed := false
+
+				if gameover || autoplay {
+					if _, done := playturn(w, !gameover); done {
 						gameover = true
 						printscore = updateScore(printscore)
 
@@ -292,86 +555,258 @@ func loop(w *app.Window) {
 							setTitle(w, "You Win!")
 							dotscreen = true
 						}
+					} else if !gameover && autoplay && !game.PlayShuffle(shuffleMode) {
+						autoplay = false
+						setTitle(w, "No shuffles left")
+					} else if !gameover && autoplay {
+						audioPlay(Shuffle)
+						setTitle(w, "")
 					}
+				}
 
-					w.Invalidate()
-
-				case "U": // undo
-					if _, _, ok := game.Undo(); ok {
-						audioPlay(Undo)
-						setTitle(w, "")
-						w.Invalidate()
+				// Handle any input from a pointer.
+				for _, ev := range gtx.Events(gDirs) {
+					ev, ok := ev.(pointer.Event)
+					if !ok {
+						continue
 					}
-				case "R": // reset
-					audioPlay(Undo)
-					game.Setup(gameWidth, gameHeight, cell.X, cell.Y)
-					setTitle(w, "Arrows")
-					gameover = false
-					dotscreen = false
-					autoplay = false
-					printscore = false
-					w.Invalidate()
-
-				case "S": // reshuffle
-					audioPlay(Shuffle)
-					game.Shuffle(shuffleDir)
-					setTitle(w, "")
-					w.Invalidate()
-
-				case "H": // help: remove all "free" arrows
-					_, gameover = playturn(w, true)
-					if gameover {
-						printscore = updateScore(printscore)
 
-						if game.Winner() {
+					switch ev.Type {
+					case pointer.Press:
+						dragStart, dragPos, dragging = ev.Position, ev.Position, false
+
+					case pointer.Drag: // pan the board
+						if d := ev.Position.Sub(dragStart); d.X*d.X+d.Y*d.Y > dragThreshold*dragThreshold {
+							dragging = true
+						}
+
+						if dragging {
+							d := dragPos.Sub(ev.Position)
+							view.scroll(image.Pt(int(d.X), int(d.Y)))
+							dragPos = ev.Position
+						}
+
+					case pointer.Scroll: // pan the board, or zoom with ctrl/cmd
+						if ev.Modifiers.Contain(key.ModShortcut) {
+							if ev.Scroll.Y < 0 {
+								view.setZoom(view.zoom+1, ev.Position.Round())
+							} else if ev.Scroll.Y > 0 {
+								view.setZoom(view.zoom-1, ev.Position.Round())
+							}
+						} else {
+							view.scroll(ev.Scroll.Round())
+						}
+
+					case pointer.Release:
+						if dragging || gameover || autoplay || ev.Position.Round().In(view.minimap) {
+							dragging = false
+							break
+						}
+
+						x, y := view.toBoard(ev.Position)
+						x, y, mov := game.Update(x, y, Move)
+						audioPlay(mov)
+						versus.Play(&game, x, y, mov)
+						outcome = moveResults[mov]
+
+						if mov != Invalid {
 							setTitle(w, "")
+						}
+
+						if game.Count == 0 {
+							gameover = true
+							printscore = updateScore(printscore)
+
+							if game.Winner() {
+								setTitle(w, "")
+							} else {
+								setTitle(w, "You Win!")
+								dotscreen = true
+							}
+
+							w.Invalidate()
 						} else {
-							setTitle(w, "You Win!")
-							dotscreen = true
+							checkStuck()
 						}
 
-						w.Invalidate()
+						pressed = true
+
+					case pointer.Move:
+						if gameover || autoplay {
+							break
+						}
+
+						// the cursor stays where it is when the pointer leaves the board
+						if x, y, dir := game.Peek(view.toBoard(ev.Position)); dir != InvalidDir {
+							cx, cy = x, y
+						}
 					}
+				}
 
-				case "P": // autoplay
-					autoplay = true
-					w.Invalidate()
+				// Register to listen for pointer and key events (taking the focus, if the board doesn't have it),
+				// and describe the board and the cursor for screen readers.
+				pr := clip.Rect(image.Rectangle{Max: e.Size}).Push(gtx.Ops)
+				pointer.InputOp{
+					Tag:          gDirs,
+					Types:        pointer.Press | pointer.Release | pointer.Move | pointer.Drag | pointer.Scroll,
+					ScrollBounds: image.Rect(-view.board.X, -view.board.Y, view.board.X, view.board.Y),
+				}.Add(gtx.Ops)
+				key.InputOp{Tag: boardTag{}, Keys: keys}.Add(gtx.Ops)
+				if !focused {
+					key.FocusOp{Tag: boardTag{}}.Add(gtx.Ops)
 				}
-			}
+
+				semantic.DescriptionOp(gameStatus).Add(gtx.Ops)
+				semantic.LabelOp(describeCursor(cx, cy, outcome)).Add(gtx.Ops)
+				pr.Pop()
+
+				return layout.Stack{Alignment: layout.Center}.Layout(gtx,
+					layout.Stacked(func(gtx layout.Context) layout.Dimensions {
+						return render(gtx, &view, cx, cy, pressed, dotscreen)
+					}),
+					layout.Stacked(func(gtx layout.Context) layout.Dimensions {
+						if statsLines != nil {
+							return renderText(gtx, statsLines)
+						}
+
+						if !gameover || scoreLines == nil {
+							return layout.Dimensions{}
+						}
+
+						return renderText(gtx, scoreLines)
+					}),
+				)
+			})
+
+			e.Frame(gtx.Ops)
 		}
 	}
 }
 
-func render(gtx layout.Context, gw, gh, px, py int, pressed, dotscreen bool) layout.Dimensions {
-	if canvas == nil {
-		canvas = imaging.New(gw, gh, bgColor)
-	} else {
-		draw.Draw(canvas, canvas.Bounds(), &image.Uniform{bgColor}, image.ZP, draw.Src)
+func render(gtx layout.Context, view *viewport, px, py int, pressed, dotscreen bool) layout.Dimensions {
+	cells := view.cells()
+	size := image.Pt(cells.Dx()*cell.X, cells.Dy()*cell.Y)
+
+	if canvas == nil || canvas.Bounds().Size() != size {
+		canvas = imaging.New(size.X, size.Y, bgColor)
 	}
 
-	for y, row := range game.Screen {
-		for x, col := range row {
-			im := gDirs[col]
-
-			if dotscreen {
-				im = gDot
-			} else if !pressed && px == x && py == y {
-				if col == Empty {
-					im = gDot
-				} else {
-					im = imaging.Invert(im)
-				}
-			}
+	renderBoard(canvas, game.Screen, cells, dotscreen)
+
+	if !pressed && !dotscreen { // no cursor while clicking, or at the end of the game
+		renderPreview(canvas, &game, cells, px, py)
+		renderCursor(canvas, game.Screen, cells, px, py)
+	}
+
+	defer clip.Rect{Max: view.size}.Push(gtx.Ops).Pop()
+	paint.Fill(gtx.Ops, bgColor) // around the board, if it's smaller than the window
+
+	// the canvas starts at the first visible cell, that can be partially outside of the window
+	scale := view.scale()
+	off := f32.Pt(float32(cells.Min.X*cell.X-view.pos.X)*scale, float32(cells.Min.Y*cell.Y-view.pos.Y)*scale)
+
+	tr := op.Affine(f32.Affine2D{}.Scale(f32.Point{}, f32.Pt(scale, scale)).Offset(off)).Push(gtx.Ops)
+	paint.NewImageOp(canvas).Add(gtx.Ops)
+	paint.PaintOp{}.Add(gtx.Ops)
+	tr.Pop()
+
+	if scoreRules.Hardcore { // a red frame around the window
+		paint.FillShape(gtx.Ops, hardcoreColor, clip.Stroke{Path: clip.Rect{Max: view.size}.Path(), Width: 2 * hardcoreFrame}.Op())
+	}
+
+	// the minimap, if the board doesn't fit the window
+	view.minimap = image.Rectangle{}
+
+	if !view.all() {
+		if mm := renderMinimap(game.Screen, cells, minimapSize); mm != nil {
+			view.minimap = mm.Bounds().Add(image.Pt(view.size.X-mm.Bounds().Dx()-minimapMargin, minimapMargin))
 
-			draw.Draw(canvas,
-				im.Bounds().Add(image.Point{x * cell.X, y * cell.Y}),
-				im, image.Point{}, draw.Over)
+			tr := op.Offset(view.minimap.Min).Push(gtx.Ops)
+			paint.NewImageOp(mm).Add(gtx.Ops)
+			paint.PaintOp{}.Add(gtx.Ops)
+			tr.Pop()
 		}
 	}
 
-	canvasOp := paint.NewImageOp(canvas)
-	img := widget.Image{Src: canvasOp}
-	img.Scale = 1 / float32(gtx.Dp(unit.Dp(1)))
+	return layout.Dimensions{Size: view.size}
+}
+
+// return the set of keys handled by the board: the cursor keys (also with shift, to pan the board)
+// and the keys bound to the actions (but "-", "," and "|", that a key.Set can't describe)
+func boardKeys() key.Set {
+	names := []string{key.NameUpArrow, key.NameDownArrow, key.NameLeftArrow, key.NameRightArrow, key.NameSpace}
+	seen := map[string]bool{}
+
+	for k := range keyBindings {
+		switch name := strings.ToUpper(k); {
+		case k == "esc":
+			names = append(names, key.NameEscape)
+		case strings.ContainsAny(k, "-,|"), seen[name]:
+		default:
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	return key.Set("(Shift)-[" + strings.Join(names, ",") + "]")
+}
+
+// describe the cursor cell for screen readers: the position, the arrow and what a click would do,
+// after the result of the last action (if any)
+func describeCursor(x, y int, outcome string) string {
+	sx, sy := game.ScreenCoords(0, 0, x, y)
+	desc := fmt.Sprintf("column %v, row %v: ", x, y)
+
+	switch _, _, dir := game.Peek(sx, sy); {
+	case dir == InvalidDir:
+		return outcome
+	case dir == Empty:
+		desc += "empty"
+	default:
+		_, _, res := game.Preview(sx, sy)
+		desc += dirNames[dir] + " arrow, " + previewNames[res]
+	}
+
+	if outcome != "" {
+		desc = outcome + ". " + desc
+	}
+
+	return desc
+}
+
+// return the key name used for the key bindings
+func gioKeyName(ev key.Event) string {
+	switch {
+	case ev.Name == key.NameEscape:
+		return "esc"
+	case len(ev.Name) == 1 && !ev.Modifiers.Contain(key.ModShift):
+		return strings.ToLower(ev.Name) // letters are reported in uppercase
+	}
+
+	return ev.Name
+}
+
+// draw some lines of text in a box
+func renderText(gtx layout.Context, lines []string) layout.Dimensions {
+	macro := op.Record(gtx.Ops)
+
+	dims := layout.UniformInset(unit.Dp(8)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		var children []layout.FlexChild
+
+		for _, l := range lines {
+			label := material.Body2(theme, l)
+			label.Font.Variant = "Mono"
+			label.Color = color.NRGBA{255, 255, 255, 255}
+			children = append(children, layout.Rigid(label.Layout))
+		}
+
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+	})
+
+	call := macro.Stop()
 
-	return img.Layout(gtx)
+	paint.FillShape(gtx.Ops, color.NRGBA{0, 0, 0, 200}, clip.Rect{Max: dims.Size}.Op())
+	call.Add(gtx.Ops)
+	return dims
 }
@@ -2,8 +2,14 @@
 
 package main
 
-func termGame(terminate func()) {}
+func termMain(terminate func()) {}
 
-func audioInit() {}
+func audioInit(dir string, synth bool) {}
+
+func audioMute() {}
+
+func audioChangeVolume(delta float64) {}
+
+func audioStatus() string { return "audio not available" }
 
 func audioPlay(mov Updates) {}
@@ -4,7 +4,9 @@ package main
 
 import (
 	"fmt"
+	"image"
 	"log"
+	"strings"
 	"time"
 
 	_ "embed"
@@ -21,16 +23,49 @@ const (
 
 	cw = 2
 	ch = 1
+
+	panelGap   = 2  // space between the board and the side panel
+	panelWidth = 20 // width of the side panel (the scoreboard)
+
+	maxCount = 9999 // maximum count prefix for the cursor movements
+
+	minView      = 10 // minimum number of visible columns when the side panel is displayed
+	scrollMargin = 1  // cells kept visible around the cursor when scrolling
+	wheelScroll  = 3  // cells scrolled by the mouse wheel
+	minimapRows  = 10 // maximum height of the minimap
 )
 
 var (
 	sx = 2
 	sy = 2
 
+	// the visible part of the board (first cell and number of cells),
+	// the board is scrolled if it doesn't fit the screen
+	vx, vy                = 0, 0
+	viewWidth, viewHeight = 0, 0
+
 	dirs = []rune{empty, up, down, left, right}
 
 	defStyle = tcell.StyleDefault.Background(tcell.ColorReset).Foreground(tcell.ColorReset)
 	boxStyle = tcell.StyleDefault.Foreground(tcell.ColorYellow).Background(tcell.ColorBlack)
+
+	// preview styles, for the arrows and the path they would follow
+	previewStyles = map[Updates]tcell.Style{
+		Remove: boxStyle.Background(tcell.ColorDarkGreen),
+		Move:   boxStyle.Background(tcell.ColorOlive),
+		None:   boxStyle.Background(tcell.ColorMaroon),
+	}
+
+	previewCells []Cell // the arrows that would move at the cursor position
+	previewEmpty []Cell // the cells they would go through
+	previewRes   = Invalid
+
+	highlightStyle = tcell.StyleDefault.Foreground(tcell.ColorBlack).Background(tcell.ColorYellow)
+	hardcoreStyle  = tcell.StyleDefault.Foreground(tcell.ColorRed).Background(tcell.ColorBlack)
+	helpStyle      = tcell.StyleDefault.Foreground(tcell.ColorWhite).Background(tcell.ColorNavy)
+
+	showHelp  = false
+	showPanel = true
 )
 
 func drawText(s tcell.Screen, x1, y1, x2, y2 int, style tcell.Style, text string) {
@@ -49,20 +84,180 @@ func drawText(s tcell.Screen, x1, y1, x2, y2 int, style tcell.Style, text string
 	}
 }
 
+// convert the coordinates of a cell to screen coordinates
+func screenPos(x, y int) (int, int) {
+	return game.ScreenCoords(sx+1-vx*cw, sy+1-vy*ch, x, y)
+}
+
+// convert screen coordinates to the coordinates used by game.Update and game.Peek
+// (only the visible part of the board is valid)
+func boardPos(x, y int) (int, int) {
+	if x <= sx || x > sx+viewWidth*cw || y <= sy || y > sy+viewHeight*ch {
+		return -1, -1
+	}
+
+	return x - sx - 1 + vx*cw, y - sy - 1 + vy*ch
+}
+
+// return true if the cell is in the visible part of the board
+func inView(x, y int) bool {
+	return x >= vx && x < vx+viewWidth && y >= vy && y < vy+viewHeight
+}
+
+// compute the part of the board that fits the screen,
+// leaving room for the side panel if there is enough
+func fitView(w, h int) {
+	avail := w - 2 // the frame
+
+	if showPanel && (avail-panelGap-panelWidth)/cw >= minView {
+		avail -= panelGap + panelWidth
+	}
+
+	viewWidth, viewHeight = game.Width, game.Height
+
+	if n := avail / cw; viewWidth > n {
+		viewWidth = n
+	}
+
+	if n := h - 3; viewHeight > n { // the frame and the status line
+		viewHeight = n
+	}
+
+	if viewWidth < 1 {
+		viewWidth = 1
+	}
+
+	if viewHeight < 1 {
+		viewHeight = 1
+	}
+
+	scrollView(0, 0)
+}
+
+// scroll the board by dx,dy cells (keeping the view inside the board)
+func scrollView(dx, dy int) {
+	vx += dx
+	vy += dy
+
+	if vx > game.Width-viewWidth {
+		vx = game.Width - viewWidth
+	}
+
+	if vx < 0 {
+		vx = 0
+	}
+
+	if vy > game.Height-viewHeight {
+		vy = game.Height - viewHeight
+	}
+
+	if vy < 0 {
+		vy = 0
+	}
+}
+
+// scroll the board so that cell x,y (and the cells around it) is visible
+func scrollTo(x, y int) {
+	dx, dy := 0, 0
+
+	if x-scrollMargin < vx {
+		dx = x - scrollMargin - vx
+	} else if x+scrollMargin >= vx+viewWidth {
+		dx = x + scrollMargin - vx - viewWidth + 1
+	}
+
+	if y-scrollMargin < vy {
+		dy = y - scrollMargin - vy
+	} else if y+scrollMargin >= vy+viewHeight {
+		dy = y + scrollMargin - vy - viewHeight + 1
+	}
+
+	scrollView(dx, dy)
+}
+
+// move the cursor by dx,dy cells (scrolling the board to follow it), returning the new cursor position
+func moveCursor(s tcell.Screen, cx, cy, dx, dy int) (int, int) {
+	x, y, ok := game.Coords(boardPos(cx, cy))
+	if !ok {
+		return cx, cy
+	}
+
+	return jumpCursor(s, x+dx, y+dy)
+}
+
+// move the cursor to the cell x,y (or the closest cell on the board), scrolling the view if needed
+func jumpCursor(s tcell.Screen, x, y int) (int, int) {
+	if x < 1 {
+		x = 1
+	} else if x > game.Width-2 {
+		x = game.Width - 2
+	}
+
+	if y < 1 {
+		y = 1
+	} else if y > game.Height-2 {
+		y = game.Height - 2
+	}
+
+	scrollTo(x, y)
+	cx, cy := screenPos(x, y)
+	checkScreen(s, cx, cy, None)
+	return cx, cy
+}
+
+// move the cursor to the next (or previous) free arrow, n times
+func nextFree(s tcell.Screen, cx, cy, n int, back bool) (int, int) {
+	x, y, ok := game.Coords(boardPos(cx, cy))
+	if !ok {
+		x, y = 1, 1
+	}
+
+	for ; n > 0; n-- {
+		if x, y, ok = game.NextFree(x, y, back); !ok {
+			showMessage(s, "No free arrows")
+			return cx, cy
+		}
+	}
+
+	return jumpCursor(s, x, y)
+}
+
 func drawScreen(s tcell.Screen) {
 	x1 := sx
 	y1 := sy
-	x2 := x1 + (game.Width * 2) + 1
-	y2 := y1 + game.Height + 1
+	x2 := x1 + (viewWidth * 2) + 1
+	y2 := y1 + viewHeight + 1
 	style := boxStyle
 
 	// Fill screen
-	for y, row := range game.Screen {
-		for x, col := range row {
-			s.SetContent(x1+(2*x)+1, y1+y+1, dirs[col], nil, style)
+	for y := vy; y < vy+viewHeight; y++ {
+		for x := vx; x < vx+viewWidth; x++ {
+			px, py := screenPos(x, y)
+			s.SetContent(px, py, dirs[game.Screen[y][x]], nil, style)
 		}
 	}
 
+	// Highlight the exit path preview
+	if pstyle, ok := previewStyles[previewRes]; ok {
+		for _, c := range previewCells {
+			if inView(c.X, c.Y) {
+				px, py := screenPos(c.X, c.Y)
+				s.SetContent(px, py, dirs[game.Screen[c.Y][c.X]], nil, pstyle)
+			}
+		}
+
+		for _, c := range previewEmpty {
+			if inView(c.X, c.Y) {
+				px, py := screenPos(c.X, c.Y)
+				s.SetContent(px, py, '\u00b7', nil, pstyle)
+			}
+		}
+	}
+
+	if scoreRules.Hardcore { // the border is red in hardcore mode
+		style = hardcoreStyle
+	}
+
 	// Draw borders
 	for col := x1; col <= x2; col++ {
 		s.SetContent(col, y1, tcell.RuneHLine, nil, style)
@@ -80,6 +275,193 @@ func drawScreen(s tcell.Screen) {
 		s.SetContent(x1, y2, tcell.RuneLLCorner, nil, style)
 		s.SetContent(x2, y2, tcell.RuneLRCorner, nil, style)
 	}
+
+	if label := " HARDCORE "; scoreRules.Hardcore && x2-x1 > len(label)+2 {
+		drawText(s, x1+2, y1, x1+2+len(label), y1, style, label)
+	}
+}
+
+func drawLines(s tcell.Screen, x, y int, lines []string) {
+	for i, l := range lines {
+		drawText(s, x, y+i, x+len(l)+1, y+i, boxStyle, l)
+	}
+}
+
+// return true if there is room for the side panel next to the board
+func panelFits(w int) bool {
+	return showPanel && w >= viewWidth*2+2+panelGap+panelWidth
+}
+
+// the list of commands (with the current key bindings)
+func helpLines() []string {
+	lines := []string{
+		"Keys",
+		"",
+		"arrows  move cursor",
+	}
+
+	// the vi keys, if they are not bound to an action
+	for _, vi := range []struct{ keys, help string }{
+		{"h j k l", "move cursor (5l: 5 cells)"},
+		{"n N", "next/previous free arrow"},
+		{"0 $", "first/last column"},
+		{"H L", "top/bottom row"},
+	} {
+		var keys []string
+		for _, k := range strings.Fields(vi.keys) {
+			if termKeyAction(k) == "" {
+				keys = append(keys, k)
+			}
+		}
+
+		if len(keys) > 0 {
+			lines = append(lines, fmt.Sprintf("%-7s %v", strings.Join(keys, " "), vi.help))
+		}
+	}
+
+	lines = append(lines, "space   move/remove arrow")
+	lines = append(lines, keyHelp()...)
+	return append(lines, "", "mouse   move cursor, click to move/remove", "wheel   scroll the board")
+}
+
+// return the key name used for the key bindings
+func termKeyName(ev *tcell.EventKey) string {
+	switch ev.Key() {
+	case tcell.KeyEscape:
+		return "esc"
+
+	case tcell.KeyRune:
+		return string(ev.Rune())
+	}
+
+	return ""
+}
+
+// draw the scoreboard next to the board (if there is room), and the help on top of everything
+func drawPanels(s tcell.Screen) {
+	w, h := s.Size()
+
+	if panelFits(w) {
+		px := sx + viewWidth*2 + 2 + panelGap
+
+		hl := -1
+		if game.Scored && newScore != nil {
+			for i, si := range scores.Get(game.Width, game.Height) {
+				if si.Same(*newScore) {
+					hl = i + 2 // skip the scoreboard header
+					break
+				}
+			}
+		}
+
+		lines := scoreboard(game.Width, game.Height)
+
+		for i, l := range lines {
+			style := boxStyle
+			if i == hl {
+				style = highlightStyle
+			}
+
+			drawText(s, px, sy+i, px+panelWidth, sy+i, style, fmt.Sprintf("%-*s", panelWidth, l))
+		}
+
+		// the minimap, if the board doesn't fit the screen
+		if my := sy + len(lines) + 1; viewWidth < game.Width || viewHeight < game.Height {
+			drawMinimap(s, px, my, panelWidth, h-my-1)
+		}
+	}
+
+	if showHelp {
+		helpLines := helpLines()
+
+		hw := 0
+		for _, l := range helpLines {
+			if len(l) > hw {
+				hw = len(l)
+			}
+		}
+
+		hw += 4 // margins
+		hh := len(helpLines) + 2
+
+		x := (w - hw) / 2
+		if x < 0 {
+			x = 0
+		}
+
+		y := (h - hh) / 2
+		if y < 0 {
+			y = 0
+		}
+
+		for r := 0; r < hh; r++ {
+			l := ""
+			if r > 0 && r <= len(helpLines) {
+				l = helpLines[r-1]
+			}
+
+			drawText(s, x, y+r, x+hw, y+r, helpStyle, fmt.Sprintf("  %-*s  ", hw-4, l))
+		}
+	}
+}
+
+// draw the map of the whole board in the area at x,y (w x h), with the visible part highlighted
+func drawMinimap(s tcell.Screen, x, y, w, h int) {
+	if h > minimapRows {
+		h = minimapRows
+	}
+
+	// each block is drawn with cw characters, as the cells of the board
+	blocks, mview := minimap(game.Screen, w/cw, h, image.Rect(vx, vy, vx+viewWidth, vy+viewHeight))
+
+	for by, row := range blocks {
+		for bx, b := range row {
+			r, style := ' ', boxStyle
+			if b {
+				r = '\u2591' // light shade
+			}
+
+			if image.Pt(bx, by).In(mview) {
+				style = highlightStyle
+			}
+
+			for i := 0; i < cw; i++ {
+				s.SetContent(x+bx*cw+i, y+by, r, nil, style)
+			}
+		}
+	}
+}
+
+// clear and redraw everything
+func redrawScreen(s tcell.Screen, cx, cy int, showStats bool) {
+	s.Clear()
+
+	if showStats {
+		drawLines(s, sx+2, sy+viewHeight+3, stats.Lines(game.Width, game.Height))
+	}
+
+	checkScreenText(s, cx, cy, None, showStats || !game.Scored) // the statistics replace the score breakdown
+}
+
+// display a message in the status line
+func showMessage(s tcell.Screen, msg string) {
+	w, _ := s.Size()
+	for x := 0; x < w; x++ {
+		s.SetContent(x, sy+viewHeight+2, ' ', nil, boxStyle)
+	}
+
+	drawText(s, sx, sy+viewHeight+2, sx+len(msg)+1, sy+viewHeight+2, boxStyle, msg)
+}
+
+// end the game if the board was cleared (or, in hardcore mode, if there are no moves left)
+func checkOver(s tcell.Screen) {
+	if game.Count == 0 {
+		game.Winner() // show the banner, if it fits
+		s.PostEvent(tcell.NewEventInterrupt(EvWin))
+	} else if game.Stuck() {
+		game.Over = true
+		s.PostEvent(tcell.NewEventInterrupt(EvWin))
+	}
 }
 
 func checkScreen(s tcell.Screen, x, y int, op Updates) (cx, cy int, mov Updates) {
@@ -89,43 +471,56 @@ func checkScreen(s tcell.Screen, x, y int, op Updates) (cx, cy int, mov Updates)
 func checkScreenText(s tcell.Screen, x, y int, op Updates, text bool) (cx, cy int, mov Updates) {
 	msg := ""
 
-	cx, cy, mov = game.Update(x-sx-1, y-sy-1, op)
+	bx, by := boardPos(x, y)
+	cx, cy, mov = game.Update(bx, by, op)
+
+	// only preview while moving around, not right after an action
+	previewCells, previewEmpty, previewRes = nil, nil, Invalid
+	if op == None {
+		previewCells, previewEmpty, previewRes = game.Preview(bx, by)
+	}
+
 	if mov != Invalid {
-		s.ShowCursor(game.ScreenCoords(sx+1, sy+1, cx, cy))
-		msg = fmt.Sprintf("moves=%v remain=%v removed=%v seq=%v/%v score=%v",
-			game.Moves, game.Count, game.Removed, game.Seq, game.MaxSeq, game.Score)
+		s.ShowCursor(screenPos(cx, cy))
+		msg = statusLine()
 	}
 
 	drawScreen(s)
 
 	w, _ := s.Size()
 	for x := 0; x < w; x++ {
-		s.SetContent(x, sy+gameHeight+2, ' ', nil, boxStyle)
+		s.SetContent(x, sy+viewHeight+2, ' ', nil, boxStyle)
 	}
 
 	if text {
-		drawText(s, sx, sy+gameHeight+2, sx+len(msg)+1, sy+gameHeight+2, boxStyle, msg)
+		drawText(s, sx, sy+viewHeight+2, sx+len(msg)+1, sy+viewHeight+2, boxStyle, msg)
 	} else {
-		var msg string
-
-		if newscore := scores.Update(&game); newscore != nil {
-			msg = fmt.Sprintf("New best score: moves=%v seq=%v score=%v\n",
-				newscore.Moves, newscore.MaxSeq, newscore.Score)
-		} else {
-			msg = fmt.Sprintf("Score: moves=%v seq=%v score=%v\n",
-				game.Moves, game.MaxSeq, game.FinalScore)
-		}
-		drawText(s, sx, sy+gameHeight+2, sx+len(msg)+1, sy+gameHeight+2, boxStyle, msg)
+		msg := recordScore()
+		drawText(s, sx, sy+viewHeight+2, sx+len(msg)+1, sy+viewHeight+2, boxStyle, msg)
+
+		drawLines(s, sx+2, sy+viewHeight+3, game.Breakdown(scoreRules).Lines())
 	}
 
+	drawPanels(s)
+
+	race.Report(&game)
 	return
 }
 
-func centerScreen(s tcell.Screen) (int, int, bool) {
-	gw, gh := game.Width*2+2, game.Height+2
+// fit the board to the screen and center it, returning the new position of the cursor at cx,cy
+func centerScreen(s tcell.Screen, cx, cy int) (int, int) {
+	x, y, ok := game.Coords(boardPos(cx, cy)) // the cell under the cursor
+
 	w, h := s.Size()
+	fitView(w, h)
+
+	gw, gh := viewWidth*2+2, viewHeight+2
 
-	px, py := sx, sy
+	if panelFits(w) {
+		gw += panelGap + panelWidth // center the board and the side panel together
+	}
+
+	sx, sy = 0, 0
 
 	if w > gw {
 		sx = (w - gw) / 2 // center horizontally
@@ -135,52 +530,82 @@ func centerScreen(s tcell.Screen) (int, int, bool) {
 		sy = (h - gh) / 2 // center vertically
 	}
 
-	if sx != px || sy != py {
-		s.Clear()
-		return game.Coords(px, py)
+	s.Clear()
+
+	if !ok {
+		return cx, cy
 	}
 
-	return -1, -1, false
+	scrollTo(x, y)
+	return screenPos(x, y)
 }
 
 const (
 	EvPlay = 1
 	EvWin  = 2
 	EvLoop = 4
+	EvRace = 8
 )
 
-func termGame(terminate func()) {
-	// Initialize screen
+// run the terminal UI on a new screen, and call terminate when the player quits
+func termMain(terminate func()) {
 	s, err := tcell.NewScreen()
 	if err != nil {
 		log.Fatalf("%+v", err)
 	}
-	if err := s.Init(); err != nil {
+
+	if err := termGame(s, nil); err != nil {
 		log.Fatalf("%+v", err)
 	}
+
+	terminate()
+}
+
+// run the terminal UI on screen s (a real terminal, or a tcell.SimulationScreen),
+// returning when the player quits (the screen is finalized).
+// ready (if not nil) is called once the board is displayed and the game waits for events,
+// and an interrupt event with a func() is run on the event loop (i.e. to inspect the screen between events)
+func termGame(s tcell.Screen, ready func()) error {
+	// Initialize screen
+	if err := s.Init(); err != nil {
+		return err
+	}
 	s.SetStyle(defStyle)
 	s.EnableMouse()
 	s.Clear()
 
 	// Draw initial screen
-	game.Setup(gameWidth, gameHeight, cw, ch)
+	startGame(cw, ch)
+	previewCells, previewEmpty, previewRes = nil, nil, Invalid // from a previous game on another screen
+	centerScreen(s, -1, -1)
 	drawScreen(s)
+	drawPanels(s)
 
 	// Event loop
-	quit := func() {
-		s.Fini()
-		terminate()
-	}
-
 	ops := map[bool]Updates{true: Move, false: None}
+	showStats := false
+	count := 0 // count prefix for the cursor movements (i.e. 5l)
 
-	cx, cy := game.ScreenCoords(sx+1, sy+1, 1, 1)
+	playing := false // autoplay is on (the autoplay key stops it)
+	looping := false // the next autoplay turn is scheduled
+
+	cx, cy := screenPos(1, 1)
 	s.ShowCursor(cx, cy)
 
+	race.SetOnUpdate(func() {
+		s.PostEvent(tcell.NewEventInterrupt(EvRace))
+	})
+	defer race.SetOnUpdate(nil) // the screen is gone when the game returns
+
 	for {
 		// Update screen
 		s.Show()
 
+		if ready != nil {
+			ready()
+			ready = nil
+		}
+
 		// Poll event
 		ev := s.PollEvent()
 
@@ -189,132 +614,219 @@ func termGame(terminate func()) {
 		case *tcell.EventResize:
 			s.Sync()
 
-			if x, y, ok := centerScreen(s); ok {
-				cx, cy = game.ScreenCoords(sx+1, sy+1, x, y)
-				s.ShowCursor(cx, cy)
-				drawScreen(s)
-			}
+			cx, cy = centerScreen(s, cx, cy)
+			s.ShowCursor(cx, cy)
+
+			drawScreen(s)
+			drawPanels(s)
 
 		case *tcell.EventKey:
 			ckey, crune := ev.Key(), ev.Rune()
+			action := termKeyAction(termKeyName(ev))
+
+			// the vi-style keys are only used if they are not bound to an action
+			vi := ckey == tcell.KeyRune && action == ""
 
-			if ckey == tcell.KeyEscape || ckey == tcell.KeyCtrlC {
-				quit()
+			pending := count
+			count = 0
+
+			n := 1 // times the cursor movement is repeated
+			if pending > 0 {
+				n = pending
+			}
+
+			if showHelp && ckey != tcell.KeyCtrlC { // any key closes the help
+				showHelp = false
+				redrawScreen(s, cx, cy, showStats)
+			} else if action == actionHelp { // show the help
+				showHelp = true
+				drawPanels(s)
+			} else if action == actionQuit || ckey == tcell.KeyCtrlC {
+				s.Fini()
+				return nil
 			} else if ckey == tcell.KeyCtrlL {
 				s.Sync()
-			} else if ckey == tcell.KeyUp {
-				if _, _, mov := checkScreen(s, cx, cy-1, None); mov != Invalid {
-					cy--
+			} else if vi && (crune >= '1' && crune <= '9' || crune == '0' && pending > 0) {
+				if count = pending*10 + int(crune-'0'); count > maxCount {
+					count = maxCount
 				}
-			} else if ckey == tcell.KeyDown {
-				if _, _, mov := checkScreen(s, cx, cy+1, None); mov != Invalid {
-					cy++
+			} else if ckey == tcell.KeyUp || vi && crune == 'k' {
+				cx, cy = moveCursor(s, cx, cy, 0, -n)
+			} else if ckey == tcell.KeyDown || vi && crune == 'j' {
+				cx, cy = moveCursor(s, cx, cy, 0, n)
+			} else if ckey == tcell.KeyLeft || vi && crune == 'h' {
+				cx, cy = moveCursor(s, cx, cy, -n, 0)
+			} else if ckey == tcell.KeyRight || vi && crune == 'l' {
+				cx, cy = moveCursor(s, cx, cy, n, 0)
+			} else if vi && (crune == 'n' || crune == 'N') {
+				cx, cy = nextFree(s, cx, cy, n, crune == 'N')
+			} else if vi && (crune == '0' || crune == '$') {
+				if x, y, ok := game.Coords(boardPos(cx, cy)); ok {
+					x = map[rune]int{'0': 1, '$': game.Width - 2}[crune]
+					cx, cy = jumpCursor(s, x, y)
 				}
-			} else if ckey == tcell.KeyLeft {
-				if _, _, mov := checkScreen(s, cx-2, cy, None); mov != Invalid {
-					cx -= 2
-				}
-			} else if ckey == tcell.KeyRight {
-				if _, _, mov := checkScreen(s, cx+2, cy, None); mov != Invalid {
-					cx += 2
+			} else if vi && (crune == 'H' || crune == 'L') {
+				if x, y, ok := game.Coords(boardPos(cx, cy)); ok {
+					y = map[rune]int{'H': 1, 'L': game.Height - 2}[crune]
+					cx, cy = jumpCursor(s, x, y)
 				}
 			} else if crune == ' ' { // hit
-				_, _, mov := checkScreen(s, cx, cy, Move)
+				x, y, mov := checkScreen(s, cx, cy, Move)
 				audioPlay(mov)
+				versus.Play(&game, x, y, mov)
+				checkScreen(s, cx, cy, None)
 
-				if game.Count == 0 {
-					if game.Winner() {
-						s.PostEvent(tcell.NewEventInterrupt(EvWin))
-					}
+				checkOver(s)
+			} else if action == actionUndo {
+				if !undoAllowed() {
+					continue
 				}
-			} else if crune == 'U' || crune == 'u' { // undo
+
 				if x, y, ok := game.Undo(); ok {
 					audioPlay(Undo)
-					cx, cy = game.ScreenCoords(sx+1, sy+1, x, y)
-					checkScreen(s, cx, cx, None)
+					versus.Undo(&game)
+					scrollTo(x, y)
+					cx, cy = screenPos(x, y)
+					checkScreen(s, cx, cy, None)
 				}
-			} else if crune == 'R' || crune == 'r' { // reset
+			} else if action == actionReset {
+				playing = false
 				audioPlay(Undo)
+				stats.Abandon(&game)
 				game.Setup(gameWidth, gameHeight, cw, ch)
+				stats.Start(&game)
+				versus.Reset()
+				s.Clear() // remove the score breakdown
 				checkScreen(s, cx, cy, None)
-			} else if crune == 'S' || crune == 's' { // reshuffle
+			} else if action == actionShuffle && !game.PlayShuffle(shuffleMode) {
+				showMessage(s, "No shuffles left")
+			} else if action == actionShuffle {
 				audioPlay(Shuffle)
-				game.Shuffle(shuffleDir)
+				versus.Shuffle(&game)
 				checkScreen(s, cx, cy, None)
-			} else if crune == 'H' || crune == 'h' { // remove all "free" arrows
-				moved := Invalid
-
-				for y := 1; y < game.Height-1; y++ {
-					for x := 1; x < game.Width-1; x++ {
-						x, y := game.ScreenCoords(0, 0, x, y)
-						_, _, mov := game.Update(x, y, Remove)
-						if mov > moved {
-							moved = mov
-						}
-					}
-				}
-
+				checkOver(s)
+			} else if action == actionHint && helpAllowed() { // remove all "free" arrows
+				game.Hints++
+				moved := game.PlayTurn()
 				audioPlay(moved)
 
 				if game.Count == 0 {
-					if game.Winner() {
-						s.PostEvent(tcell.NewEventInterrupt(EvWin))
-					}
+					game.Winner() // show the banner, if it fits
+					s.PostEvent(tcell.NewEventInterrupt(EvWin))
 				} else if moved != None {
 					game.Seq = 0
 				}
 
 				checkScreen(s, cx, cy, None)
-			} else if crune == 'P' || crune == 'p' { // auto play
-				s.PostEvent(tcell.NewEventInterrupt(EvPlay))
+			} else if action == actionAutoplay && playing {
+				playing = false
+				showMessage(s, "Autoplay stopped")
+			} else if action == actionAutoplay && helpAllowed() {
+				game.Autoplay = true
+				playing = true
+
+				if !looping { // otherwise the scheduled turn restarts it
+					s.PostEvent(tcell.NewEventInterrupt(EvPlay))
+				}
+			} else if action == actionMute {
+				audioMute()
+				showMessage(s, audioStatus())
+			} else if action == actionVolumeDown {
+				audioChangeVolume(-0.5)
+				showMessage(s, audioStatus())
+			} else if action == actionVolumeUp {
+				audioChangeVolume(0.5)
+				showMessage(s, audioStatus())
+			} else if action == actionStats {
+				showStats = !showStats
+				redrawScreen(s, cx, cy, showStats)
+			} else if action == actionScoreboard { // show/hide the side panel
+				showPanel = !showPanel
+				cx, cy = centerScreen(s, cx, cy)
+				redrawScreen(s, cx, cy, showStats)
+			} else if action == actionSnapshot {
+				filename := captureName(&game, "png")
+				if err := saveSnapshot(filename, game.Screen); err != nil {
+					showMessage(s, err.Error())
+				} else {
+					showMessage(s, "saved "+filename)
+				}
+			} else if action == actionReplay {
+				filename := captureName(&game, "gif")
+				if err := saveGIF(filename, replayFrames(&game)); err != nil {
+					showMessage(s, err.Error())
+				} else {
+					showMessage(s, "saved "+filename)
+				}
 			}
 		case *tcell.EventMouse:
+			switch buttons := ev.Buttons(); { // the wheel scrolls the board
+			case buttons&tcell.WheelUp != 0:
+				scrollView(0, -wheelScroll)
+			case buttons&tcell.WheelDown != 0:
+				scrollView(0, wheelScroll)
+			case buttons&tcell.WheelLeft != 0:
+				scrollView(-wheelScroll, 0)
+			case buttons&tcell.WheelRight != 0:
+				scrollView(wheelScroll, 0)
+			}
+
 			cx, cy = ev.Position()
 			pressed := ev.Buttons()&tcell.ButtonMask(0xff) != tcell.ButtonNone
-			_, _, mov := checkScreen(s, cx, cy, ops[pressed])
+			x, y, mov := checkScreen(s, cx, cy, ops[pressed])
 			if pressed {
 				audioPlay(mov)
+				versus.Play(&game, x, y, mov)
+				checkScreen(s, cx, cy, None)
 
-				if game.Count == 0 {
-					if game.Winner() {
-						s.PostEvent(tcell.NewEventInterrupt(EvWin))
-					}
-				}
+				checkOver(s)
 			}
 
 		case *tcell.EventInterrupt:
+			if fn, ok := ev.Data().(func()); ok {
+				fn()
+				continue
+			}
+
 			evType := ev.Data().(int)
-			changes := false
-
-			for y := 1; y < game.Height-1; y++ {
-				for x := 1; x < game.Width-1; x++ {
-					x, y := game.ScreenCoords(0, 0, x, y)
-					_, _, mov := game.Update(x, y, Remove)
-					if mov > None {
-						changes = true
-					}
+
+			if evType&EvPlay == EvPlay {
+				if evType&EvLoop == EvLoop {
+					looping = false
 				}
+
+				if !playing { // autoplay was stopped
+					continue
+				}
+			}
+
+			if evType == EvRace { // other players status changed
+				checkScreen(s, cx, cy, None)
+				continue
 			}
 
+			changes := game.PlayTurn() > None
+
 			checkScreenText(s, cx, cy, None, (evType&EvPlay) == EvPlay)
 
 			if evType == EvWin || changes {
 				if (evType & EvPlay) == EvPlay { // autoplay
 					if game.Count == 0 {
-						if game.Winner() {
-							evType = EvWin
-						} else {
-							continue
-						}
-					} else {
+						game.Winner() // show the banner, if it fits
+						evType = EvWin
+						playing = false
+					} else if game.PlayShuffle(shuffleMode) {
 						audioPlay(Shuffle)
-						game.Shuffle(shuffleDir)
 					}
 				}
 
+				looping = evType&EvPlay == EvPlay
+
 				time.AfterFunc(300*time.Millisecond, func() {
 					s.PostEvent(tcell.NewEventInterrupt(evType | EvLoop))
 				})
+			} else if evType&EvPlay == EvPlay { // nothing left to play
+				playing = false
 			}
 		}
 	}
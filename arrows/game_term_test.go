@@ -0,0 +1,514 @@
+//go:build !ios && !android && !js
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// the terminal UI running on a simulated screen, and a copy of the game
+// (played in the test, to know what the screen should show).
+// The screen is only read on the event loop, between two events.
+type termTest struct {
+	t    *testing.T
+	s    tcell.SimulationScreen
+	ref  *Game
+	done chan error
+
+	ox, oy int // screen position of the cell 1,1
+}
+
+// start the terminal UI on a w x h board
+func startTerm(t *testing.T, w, h int, seed int64) *termTest {
+	t.Helper()
+
+	st := storage
+	t.Cleanup(func() {
+		storage = st
+		race, keyBindings, customKeys, showHelp, showPanel = nil, bindKeys(nil), nil, false, true
+	})
+
+	storage = newMemStorage()
+	gameWidth, gameHeight, gameSeed = w+2, h+2, seed
+	gameDifficulty, seedRated, resumeGame = "", false, false
+	scoreRules, shuffleMode = ClassicRules, ShuffleRandom
+	versus = nil
+
+	tt := &termTest{t: t, s: tcell.NewSimulationScreen("UTF-8"), ref: &Game{}, done: make(chan error, 1)}
+	tt.ref.SetupSeed(gameWidth, gameHeight, cw, ch, seed)
+
+	ready := make(chan struct{})
+
+	go func() {
+		tt.done <- termGame(tt.s, func() {
+			tt.ox, tt.oy, _ = tt.s.GetCursor() // the cursor starts on cell 1,1
+			close(ready)
+		})
+	}()
+
+	select {
+	case <-ready:
+	case err := <-tt.done:
+		t.Fatalf("the game returned before starting: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the game to start")
+	}
+
+	tt.waitBoard("the board")
+	return tt
+}
+
+// quit the game, and wait for termGame to return
+func (tt *termTest) quit() {
+	tt.t.Helper()
+
+	tt.s.PostEventWait(tcell.NewEventKey(tcell.KeyEscape, 0, tcell.ModNone))
+	if err := <-tt.done; err != nil {
+		tt.t.Fatal(err)
+	}
+}
+
+// type the keys
+func (tt *termTest) keys(keys string) {
+	for _, r := range keys {
+		tt.s.PostEventWait(tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone))
+	}
+}
+
+// click on cell x,y
+func (tt *termTest) click(x, y int) {
+	px, py := tt.pos(x, y)
+	tt.s.PostEventWait(tcell.NewEventMouse(px, py, tcell.Button1, tcell.ModNone))
+	tt.s.PostEventWait(tcell.NewEventMouse(px, py, tcell.ButtonNone, tcell.ModNone))
+}
+
+// run fn on the event loop (so that the screen is not being drawn)
+func (tt *termTest) sync(fn func()) {
+	tt.t.Helper()
+
+	done := make(chan struct{})
+	tt.s.PostEventWait(tcell.NewEventInterrupt(func() {
+		fn()
+		close(done)
+	}))
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		tt.t.Fatal("timeout waiting for the event loop")
+	}
+}
+
+// wait until cond (run on the event loop) is true
+func (tt *termTest) wait(what string, cond func() bool) {
+	tt.t.Helper()
+
+	waitFor(tt.t, what, func() (ok bool) {
+		tt.sync(func() { ok = cond() })
+		return
+	})
+}
+
+// play cell x,y in the test game (as the space key, or a click)
+func (tt *termTest) update(x, y int) {
+	sx, sy := tt.ref.ScreenCoords(0, 0, x, y)
+	tt.ref.Update(sx, sy, Move)
+}
+
+// the screen position of cell x,y
+func (tt *termTest) pos(x, y int) (int, int) {
+	return tt.ox + (x-1)*cw, tt.oy + (y-1)*ch
+}
+
+// the cell under the cursor (on the event loop)
+func (tt *termTest) cursor() (int, int) {
+	x, y, _ := tt.s.GetCursor()
+	return (x-tt.ox)/cw + 1, (y-tt.oy)/ch + 1
+}
+
+// the board displayed on the screen (on the event loop)
+func (tt *termTest) board() [][]Dir {
+	cells, w, h := tt.s.GetContents()
+
+	board := make([][]Dir, tt.ref.Height)
+	for y := range board {
+		board[y] = make([]Dir, tt.ref.Width)
+	}
+
+	for y := 1; y < tt.ref.Height-1; y++ {
+		for x := 1; x < tt.ref.Width-1; x++ {
+			px, py := tt.pos(x, y)
+			if px < 0 || px >= w || py < 0 || py >= h || len(cells[py*w+px].Runes) == 0 {
+				return nil
+			}
+
+			switch r := cells[py*w+px].Runes[0]; r {
+			case empty, '\u00b7': // the path preview
+				board[y][x] = Empty
+			default:
+				d := indexRune(dirs, r)
+				if d < 0 {
+					return nil
+				}
+
+				board[y][x] = Dir(d)
+			}
+		}
+	}
+
+	return board
+}
+
+// the text on the screen (on the event loop)
+func (tt *termTest) text() string {
+	cells, w, _ := tt.s.GetContents()
+
+	var b strings.Builder
+	for i, c := range cells {
+		if i > 0 && i%w == 0 {
+			b.WriteByte('\n')
+		}
+
+		if len(c.Runes) > 0 {
+			b.WriteRune(c.Runes[0])
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+
+	return b.String()
+}
+
+// the number of arrows on the screen (on the event loop)
+func (tt *termTest) arrows() (n int) {
+	for _, row := range tt.board() {
+		for _, d := range row {
+			if d != Empty {
+				n++
+			}
+		}
+	}
+
+	return
+}
+
+// wait until the cursor is on cell x,y
+func (tt *termTest) waitCursor(what string, x, y int) {
+	tt.t.Helper()
+
+	tt.wait(what, func() bool {
+		cx, cy := tt.cursor()
+		return cx == x && cy == y
+	})
+}
+
+// wait until the screen shows the board of the test game
+func (tt *termTest) waitBoard(what string) {
+	tt.t.Helper()
+
+	tt.wait(what, func() bool {
+		board := tt.board()
+		return board != nil && equalBoards(board, tt.ref.Screen)
+	})
+}
+
+// compare the boards (without the border)
+func equalBoards(b1, b2 [][]Dir) bool {
+	for y := 1; y < len(b1)-1; y++ {
+		for x := 1; x < len(b1[y])-1; x++ {
+			if b1[y][x] != b2[y][x] {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func indexRune(runes []rune, r rune) int {
+	for i, c := range runes {
+		if c == r {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func TestTermViKeys(t *testing.T) {
+	tt := startTerm(t, 10, 8, 42)
+	defer tt.quit()
+
+	tt.keys("3l")
+	tt.waitCursor("3l", 4, 1)
+
+	tt.keys("2j")
+	tt.waitCursor("2j", 4, 3)
+
+	tt.keys("k")
+	tt.waitCursor("k", 4, 2)
+
+	tt.keys("2h")
+	tt.waitCursor("2h", 2, 2)
+
+	// counts stop at the edge of the board
+	tt.keys("5l")
+	tt.waitCursor("5l", 7, 2)
+
+	tt.keys("5l")
+	tt.waitCursor("5l at the edge", 10, 2)
+
+	tt.keys("12j")
+	tt.waitCursor("12j", 10, 8)
+
+	tt.keys("0")
+	tt.waitCursor("0", 1, 8)
+
+	tt.keys("H")
+	tt.waitCursor("H", 1, 1)
+
+	tt.keys("$")
+	tt.waitCursor("$", 10, 1)
+
+	tt.keys("L")
+	tt.waitCursor("L", 10, 8)
+
+	// 10j is a count, not the 0 key
+	tt.keys("H10j")
+	tt.waitCursor("10j", 10, 8)
+}
+
+func TestTermNextFree(t *testing.T) {
+	tt := startTerm(t, 10, 8, 42)
+	defer tt.quit()
+
+	var free []Cell
+	tt.ref.index().each(func(x, y int) { free = append(free, Cell{X: x, Y: y}) })
+
+	if len(free) < 2 {
+		t.Fatalf("only %v free arrows", len(free))
+	}
+
+	first, fy, _ := tt.ref.NextFree(1, 1, false) // from the cursor
+
+	tt.keys("n")
+	tt.waitCursor("n", first, fy)
+
+	second, sy, _ := tt.ref.NextFree(first, fy, false)
+	tt.keys("n")
+	tt.waitCursor("n", second, sy)
+
+	tt.keys("N")
+	tt.waitCursor("N", first, fy)
+
+	// from the first free arrow, N wraps around to the last one
+	last, ly, _ := tt.ref.NextFree(first, fy, true)
+	tt.keys("N")
+	tt.waitCursor("N (wrap)", last, ly)
+
+	// and n wraps around to the first one
+	tt.keys("n")
+	tt.waitCursor("n (wrap)", first, fy)
+
+	// with a count
+	tt.keys("2n")
+	x, y := first, fy
+	for i := 0; i < 2; i++ {
+		x, y, _ = tt.ref.NextFree(x, y, false)
+	}
+	tt.waitCursor("2n", x, y)
+}
+
+func TestTermCursorUndoReset(t *testing.T) {
+	tt := startTerm(t, 10, 8, 42)
+	defer tt.quit()
+
+	x, y, _ := tt.ref.NextFree(1, 1, false)
+
+	tt.keys("n ")
+	tt.update(x, y)
+	tt.waitBoard("move")
+
+	// the cursor goes back to the cell restored by the undo
+	tt.keys("9k0")
+	tt.waitCursor("9k0", 1, 1)
+
+	tt.keys("u")
+	ux, uy, _ := tt.ref.Undo()
+	tt.waitBoard("undo")
+	tt.waitCursor("undo", ux, uy)
+
+	if tt.ref.Screen[uy][ux] == Empty {
+		t.Errorf("cursor on the empty cell %v,%v after undo", ux, uy)
+	}
+
+	tt.keys("9k0")
+	tt.waitCursor("9k0", 1, 1)
+	tt.keys(strings.Repeat("l", x-1) + strings.Repeat("j", y-1))
+	tt.waitCursor("back to the arrow", x, y)
+
+	// the cursor stays where it is after a reset
+	tt.keys(" 3j")
+	tt.update(x, y)
+	tt.waitBoard("move")
+
+	cy := y + 3
+	if cy > tt.ref.Height-2 {
+		cy = tt.ref.Height - 2
+	}
+	tt.waitCursor("3j", x, cy)
+
+	tt.keys("r")
+	tt.ref.SetupSeed(gameWidth, gameHeight, cw, ch, gameSeed)
+	tt.waitBoard("reset")
+	tt.waitCursor("reset", x, cy)
+}
+
+func TestTermHelpViKeys(t *testing.T) {
+	defer func() { keyBindings, customKeys = bindKeys(nil), nil }()
+
+	// by default the vi keys move the cursor and F and f are the hint
+	help := strings.Join(helpLines(), "\n")
+	if !strings.Contains(help, "h j k l move cursor") || !strings.Contains(help, "H L     top/bottom row") ||
+		!strings.Contains(help, "F f     help:") {
+		t.Errorf("wrong vi keys in the help:\n%v", help)
+	}
+
+	// unless the keys are set in the configuration file
+	customKeys = map[string]string{actionHint: "h", actionUndo: "H"}
+	keyBindings = bindKeys(customKeys)
+
+	help = strings.Join(helpLines(), "\n")
+	if !strings.Contains(help, "j k l   move cursor") || !strings.Contains(help, "\nL       top/bottom row") ||
+		!strings.Contains(help, "\nh       help:") || !strings.Contains(help, "\nH       undo") {
+		t.Errorf("wrong vi keys in the help:\n%v", help)
+	}
+}
+
+func TestTermPlay(t *testing.T) {
+	tt := startTerm(t, 10, 8, 42)
+	defer tt.quit()
+
+	x, y, _ := tt.ref.NextFree(0, 0, false)
+
+	tt.click(x, y)
+	tt.update(x, y)
+	tt.waitBoard("click")
+	tt.waitCursor("click", x, y)
+
+	x, y, _ = tt.ref.NextFree(x, y, false)
+
+	tt.keys("n ")
+	tt.update(x, y)
+	tt.waitBoard("space")
+
+	tt.keys("u")
+	tt.ref.Undo()
+	tt.waitBoard("undo")
+
+	tt.keys("s")
+	tt.ref.Shuffle(shuffleMode)
+	tt.waitBoard("shuffle")
+
+	// the hint removes all the free arrows, the cursor doesn't move
+	var cx, cy int
+	tt.sync(func() { cx, cy = tt.cursor() })
+
+	tt.keys("f") // h moves the cursor
+	tt.ref.PlayTurn()
+	tt.waitBoard("hint")
+	tt.waitCursor("hint", cx, cy)
+}
+
+// wait for the next autoplay turn, returning the arrows left
+func (tt *termTest) waitTurn(n int) (left int) {
+	tt.t.Helper()
+
+	tt.wait("autoplay turn", func() bool {
+		left = tt.arrows()
+		return left < n
+	})
+
+	return
+}
+
+func TestTermAutoplay(t *testing.T) {
+	tt := startTerm(t, 10, 8, 42)
+	defer tt.quit()
+
+	var n int
+	tt.sync(func() { n = tt.arrows() })
+
+	tt.keys("p")
+
+	// the turns are played one after the other (EvPlay, then EvLoop)
+	for turn := 0; turn < 2; turn++ {
+		n = tt.waitTurn(n)
+	}
+
+	// the autoplay key stops it
+	tt.keys("p")
+	tt.wait("autoplay stopped", func() bool { return strings.Contains(tt.text(), "Autoplay stopped") })
+	tt.sync(func() { n = tt.arrows() })
+
+	time.Sleep(time.Second) // more than a turn
+
+	tt.sync(func() {
+		if left := tt.arrows(); left != n {
+			t.Errorf("autoplay went on after it was stopped: %v arrows, then %v", n, left)
+		}
+	})
+
+	// and starts it again
+	tt.keys("p")
+	tt.waitTurn(n)
+}
+
+func TestTermAutoplayQuit(t *testing.T) {
+	tt := startTerm(t, 10, 8, 42)
+
+	var n int
+	tt.sync(func() { n = tt.arrows() })
+
+	tt.keys("p")
+	tt.waitTurn(n)
+
+	// quit while the next turn is scheduled
+	tt.quit()
+
+	count := game.Count
+	time.Sleep(time.Second)
+
+	if game.Count != count {
+		t.Errorf("autoplay went on after the game returned: %v arrows, then %v", count, game.Count)
+	}
+}
+
+func TestTermRaceHook(t *testing.T) {
+	race = newRace("alice")
+
+	tt := startTerm(t, 10, 8, 42)
+
+	race.Lock()
+	hooked := race.onUpdate != nil
+	race.Unlock()
+
+	if !hooked {
+		t.Error("the game doesn't follow the race")
+	}
+
+	race.notify() // redraws the screen
+	tt.quit()
+
+	race.Lock()
+	hooked = race.onUpdate != nil
+	race.Unlock()
+
+	if hooked {
+		t.Error("the race still updates the screen after the game returned")
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// play the game shuffling n times, and return the boards after each shuffle
+func shuffleBoards(g *Game, n int) (boards [][][]Dir) {
+	for i := 0; i < n; i++ {
+		g.Shuffle(ShuffleRandom)
+		boards = append(boards, copyScreen(g.Screen))
+	}
+
+	return
+}
+
+func TestCloneKeepsRandom(t *testing.T) {
+	var g1, g2 Game
+
+	g1.SetupSeed(12, 10, 1, 1, 42)
+	g2.SetupSeed(12, 10, 1, 1, 42)
+
+	// cloning (i.e. saving a replay) shouldn't change how the original plays
+	c := g1.Clone()
+	c.Shuffle(ShuffleRandom)
+	g1.Clone()
+
+	if !reflect.DeepEqual(shuffleBoards(&g1, 5), shuffleBoards(&g2, 5)) {
+		t.Error("cloning the game changed the shuffles")
+	}
+}
+
+// return a blocked arrow (that cannot move at all)
+func blockedArrow(t *testing.T, g *Game) (int, int) {
+	t.Helper()
+
+	for y := 1; y < g.Height-1; y++ {
+		for x := 1; x < g.Width-1; x++ {
+			if d, _, _, _, ok := g.path(x, y); d != Empty && !ok {
+				return x, y
+			}
+		}
+	}
+
+	t.Fatal("no blocked arrows")
+	return -1, -1
+}
+
+func TestBlockedOnlyInHardcore(t *testing.T) {
+	for _, hardcore := range []bool{false, true} {
+		var g Game
+		g.SetupSeed(12, 10, 1, 1, 42)
+		g.Hardcore = hardcore
+
+		x, y := blockedArrow(t, &g)
+		if _, _, res := g.Update(x, y, Move); res != None {
+			t.Fatalf("blocked arrow at %v,%v: %v", x, y, res)
+		}
+
+		want := 0
+		if hardcore {
+			want = 1
+		}
+
+		if g.Blocked != want {
+			t.Errorf("hardcore=%v: blocked=%v, want %v", hardcore, g.Blocked, want)
+		}
+	}
+}
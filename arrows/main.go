@@ -1,20 +1,27 @@
 package main
 
 import (
-	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"runtime"
+	"time"
 )
 
 var (
 	gameWidth  = 20
 	gameHeight = 20
 
-	shuffleDir = Empty // random
-	scorefile  = os.ExpandEnv("${HOME}/.arrows")
+	shuffleMode = ShuffleRandom
+	gameSeed    = int64(0) // random
+	scoreRules  = ClassicRules
+	resumeGame  = false
+
+	boardParams = DefaultParams
+
+	gameDifficulty = ""    // any
+	seedRated      = false // gameSeed is already a board at gameDifficulty (chosen by the race host)
 )
 
 func hasTerm() bool {
@@ -33,77 +40,376 @@ func main() {
 	flag.IntVar(&gameWidth, "width", gameWidth, "screen width")
 	flag.IntVar(&gameHeight, "height", gameHeight, "screen height")
 	audio := flag.Bool("audio", true, "play audio effects")
-	sdir := flag.String("shuffle", "random", "shuffle direction (left, right, random)")
+	synth := flag.Bool("synth", false, "play generated sound effects, rising with the sequence of removed arrows")
+	sounds := flag.String("sounds", "", "directory with replacement sound effects (remove.wav, move.wav, stop.wav, shuffle.wav, undo.wav)")
+	sdir := flag.String("shuffle", "random", "shuffle mode (random, left, right, flip, blocked, minimal)")
+	mode := flag.String("mode", "normal", "game mode (normal, hardcore: no undo, no hints, blocked arrows and shuffles cost points)")
+	budget := flag.Int("shuffles", 0, "shuffles allowed in a game, each one costing points (0 for no limit)")
 	score := flag.Bool("score", false, "display scoreboard")
+	flag.Int64Var(&gameSeed, "seed", gameSeed, "board seed (0 for a random board)")
+	simulate := flag.Int("simulate", 0, "simulate the specified number of boards and print statistics")
+	format := flag.String("format", "csv", "simulation output format (csv, json)")
+	host := flag.String("host", "", "host a race, listening on the specified address (i.e. :5000)")
+	join := flag.String("join", "", "join a race hosted at the specified address (i.e. localhost:5000)")
+	name := flag.String("name", os.Getenv("USER"), "player name (for races)")
+	flag.BoolVar(&resumeGame, "resume", resumeGame, "resume the last unfinished game (if the board size matches)")
+	showStats := flag.Bool("stats", false, "display lifetime statistics")
+	rules := flag.String("rules", scoreRules.Name, "scoring rules (classic, fair, strict)")
+	dailyGame := flag.Bool("daily", false, "play the daily challenge")
+	players := flag.Int("players", 1, "number of players, taking turns on the same board (hot-seat versus mode)")
+	snapshot := flag.String("snapshot", "", "save the board as a PNG image, without playing")
+	gifout := flag.String("gif", "", "autoplay the board and save the game as an animated GIF, without playing")
+	serve := flag.String("serve", "", "serve the game API (HTTP/JSON) on the specified address (i.e. :8080)")
+	games := flag.Int("games", 1, "number of boards played by the bots")
+	moveTime := flag.Duration("movetime", 5*time.Second, "time limit for a bot move")
+	density := flag.Float64("density", DefaultParams.Density, "fraction of the cells with an arrow (more than 0, up to 1)")
+	weights := flag.String("weights", "1,1,1,1", "relative frequency of the up,down,left,right arrows")
+	cluster := flag.Float64("cluster", DefaultParams.Cluster, "probability (0 to 1) that an arrow continues the run of its neighbour")
+	difficulty := flag.String("difficulty", gameDifficulty, "generate boards until one is at the specified difficulty (easy, medium, hard)")
+
+	var bots BotList
+	flag.Var(&bots, "bot", "play with the specified bot program (can be repeated, for a tournament)")
 
 	if hasTerm() {
 		flag.BoolVar(&term, "term", term, "terminal UI vs. graphics UI")
 	}
 
+	loadConfig() // set the defaults from the configuration file
+
 	flag.Parse()
 
 	if gameWidth <= 0 || gameHeight <= 0 {
 		log.Fatal("invalid width or height")
 	}
 
-	if f, err := os.Open(scorefile); err == nil {
-		dec := json.NewDecoder(f)
-		if err := dec.Decode(&scores); err != nil {
-			log.Printf("cannot read %v: %v", scorefile, err)
+	if r, ok := ScorePresets[*rules]; ok {
+		scoreRules = r
+	} else {
+		log.Fatalf("invalid scoring rules %q", *rules)
+	}
+
+	if p, err := NewBoardParams(*density, *weights, *cluster); err != nil {
+		log.Fatal(err)
+	} else {
+		boardParams = p
+	}
+
+	if !validLevel(*difficulty) {
+		log.Fatalf("invalid difficulty %q", *difficulty)
+	}
+
+	gameDifficulty = *difficulty
+
+	if mode, ok := shuffleModes[*sdir]; ok {
+		shuffleMode = mode
+	} else {
+		log.Fatalf("invalid shuffle mode %q", *sdir)
+	}
+
+	if *budget < 0 {
+		log.Fatal("invalid number of shuffles")
+	} else if *budget > 0 {
+		scoreRules = scoreRules.WithBudget(*budget)
+	}
+
+	switch *mode {
+	case "normal":
+
+	case "hardcore":
+		scoreRules = scoreRules.WithHardcore()
+
+	default:
+		log.Fatalf("invalid game mode %q", *mode)
+	}
+
+	if *simulate > 0 {
+		gameWidth += 2  // add border
+		gameHeight += 2 // to simplify boundary checks
+
+		if err := simulateGames(*simulate, *format, os.Stdout); err != nil {
+			log.Fatal(err)
 		}
-		f.Close()
+
+		return
 	}
 
-	defer terminateMain()
+	if *serve != "" {
+		log.Fatal(serveAPI(*serve))
+	}
+
+	if len(bots) > 0 {
+		gameWidth += 2  // add border
+		gameHeight += 2 // to simplify boundary checks
+
+		if err := runTournament(bots, *games, *moveTime, *format, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+
+		return
+	}
+
+	if *host != "" && *join != "" {
+		log.Fatal("cannot host and join a race at the same time")
+	}
+
+	if *players > 1 {
+		if *host != "" || *join != "" {
+			log.Fatal("versus mode is not available in a race")
+		}
+
+		versus = newVersus(*players)
+	}
+
+	if *name == "" {
+		*name = "player"
+	}
+
+	// the daily board is set before hosting, so that the race is on the same board
+	if *dailyGame {
+		if *join != "" || versus != nil {
+			log.Fatal("the daily challenge is a single player game")
+		}
+
+		if gameDifficulty != "" {
+			log.Fatal("the daily challenge is the same board for everyone, it cannot be combined with -difficulty")
+		}
+
+		dailyDate = today()
+		gameSeed = dailySeed(dailyDate, gameWidth, gameHeight)
+	}
+
+	if *host != "" {
+		r, err := hostRace(*host, *name)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		race = r
+	} else if *join != "" {
+		r, err := joinRace(*join, *name)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		race = r
+	}
+
+	if *snapshot != "" || *gifout != "" {
+		gameWidth += 2  // add border
+		gameHeight += 2 // to simplify boundary checks
+
+		if err := headless(*snapshot, *gifout); err != nil {
+			log.Fatal(err)
+		}
+
+		return
+	}
+
+	loadScores()
 
 	gameWidth += 2  // add border
 	gameHeight += 2 // to simplify boundary checks
 
-	if *score {
+	if *showStats {
+		for _, size := range stats.Sizes() {
+			fmt.Println()
+			for _, l := range stats.Lines(size[0], size[1]) {
+				fmt.Println(l)
+			}
+		}
+
+		return
+	}
+
+	if *score && *dailyGame {
+		current, longest := daily.Streaks(gameWidth, gameHeight)
+		best := daily.Best(gameWidth, gameHeight)
+
 		fmt.Println()
-		fmt.Println("       Scoreboard")
-		fmt.Println("     Moves Seq Score")
+		fmt.Println("       Daily challenge")
+		fmt.Printf("  streak=%v longest=%v\n", current, longest)
+		fmt.Println("       Date   Moves Seq Score")
+
+		for i, d := range daily.Get(gameWidth, gameHeight) {
+			mark := ""
+			if i == best {
+				mark = " *best*"
+			}
 
-		for i, s := range scores.Get(gameWidth, gameHeight) {
-			fmt.Printf("%2d:  %4d  %3d %5d\n", i+1, s.Moves, s.MaxSeq, s.Score)
+			fmt.Printf("  %v  %4d  %3d %5d%v\n", d.Date, d.Moves, d.MaxSeq, d.Score, mark)
 		}
 
 		return
 	}
 
-	switch *sdir {
-	case "l", "left":
-		shuffleDir = Left
+	if *score {
+		fmt.Println()
+		for _, l := range scoreboard(gameWidth, gameHeight) {
+			fmt.Println(l)
+		}
 
-	case "r", "right":
-		shuffleDir = Right
+		return
+	}
 
-	default:
-		shuffleDir = Empty
+	if dailyDate != "" {
+		if di := daily.Played(gameWidth, gameHeight, dailyDate); di != nil {
+			fmt.Printf("Daily challenge for %v already completed (score=%v), this game will not be scored\n", dailyDate, di.Score)
+		}
 	}
 
+	defer terminateMain()
+
 	// Initialize audio
 	if *audio {
-		audioInit()
+		audioInit(*sounds, *synth)
 	}
 
 	if term {
-		termGame(terminateMain)
+		termMain(terminateMain)
 	} else {
 		gioGame(terminateMain)
 	}
 }
 
-func terminateMain() {
-	if f, err := os.Create(scorefile); err == nil {
-		enc := json.NewEncoder(f)
-		if err := enc.Encode(scores); err != nil {
-			log.Printf("cannot write %v: %v", scorefile, err)
+// return the game status (for the window title or the terminal status line)
+func statusLine() string {
+	msg := fmt.Sprintf("moves=%v remain=%v removed=%v seq=%v/%v score=%v",
+		game.Moves, game.Count, game.Removed, game.Seq, game.MaxSeq, game.Score)
+
+	if scoreRules.Hardcore {
+		msg = "HARDCORE " + msg
+	}
+
+	if scoreRules.BlockedPenalty > 0 {
+		msg += fmt.Sprintf(" blocked=%v", game.Blocked)
+	}
+
+	if game.ShufflesLeft() >= 0 {
+		msg += fmt.Sprintf(" shuffles=%v/%v", game.Shuffles, scoreRules.ShuffleBudget)
+	}
+
+	if game.Moves == 0 && game.Difficulty.Level != "" { // show the rating at the start of the game
+		msg += " difficulty=" + game.Difficulty.String()
+	}
+
+	if race != nil {
+		msg += " | " + race.Status()
+	}
+
+	if versus != nil {
+		if game.Completed {
+			msg += " | " + versus.Result()
+		} else {
+			msg += " | " + versus.Status()
 		}
-		f.Close()
-	} else {
-		log.Println(err)
 	}
 
+	return msg
+}
+
+// return the scoreboard for the board size, one line per entry
+func scoreboard(width, height int) []string {
+	lines := []string{
+		"       Scoreboard",
+		"   Moves Seq Score D",
+	}
+
+	if scoreRules.Hardcore {
+		lines[0] = " Hardcore scoreboard"
+	}
+
+	for i, s := range scores.Get(width, height) {
+		lines = append(lines, fmt.Sprintf("%2d: %4d %3d %5d %v", i+1, s.Moves, s.MaxSeq, s.Score, levelInitial(s.Difficulty)))
+	}
+
+	return lines
+}
+
+var (
+	scoreMessage string
+	newScore     *ScoreInfo // the scoreboard entry of the last game, if it was a new best score
+)
+
+// update the scoreboard and statistics at the end of a game
+// and return a message with the result
+func recordScore() string {
+	if !game.Scored {
+		if game.Over { // hardcore game with no moves left
+			audioPlay(None)
+			stats.Abandon(&game)
+		} else {
+			audioPlay(Win)
+			stats.Complete(&game)
+		}
+
+		game.Scored = true
+		scoreMessage = updateScores()
+		saveScores()
+
+		if game.Over {
+			scoreMessage = "No moves left! " + scoreMessage
+		}
+	}
+
+	return scoreMessage
+}
+
+func updateScores() string {
+	newScore = nil
+
+	if versus != nil {
+		return versus.Result()
+	}
+
+	if dailyDate != "" {
+		info, ok := daily.Update(&game, dailyDate)
+		if !ok {
+			return fmt.Sprintf("Daily challenge %v already completed: score=%v (this game: %v)",
+				info.Date, info.Score, game.ComputeScore())
+		}
+
+		current, _ := daily.Streaks(game.Width, game.Height)
+		return fmt.Sprintf("Daily challenge %v: moves=%v seq=%v score=%v streak=%v",
+			info.Date, info.Moves, info.MaxSeq, info.Score, current)
+	}
+
+	if newscore := scores.Update(&game); newscore != nil {
+		newScore = newscore
+		return fmt.Sprintf("New best score: moves=%v seq=%v score=%v",
+			newscore.Moves, newscore.MaxSeq, newscore.Score)
+	}
+
+	return fmt.Sprintf("Score: moves=%v seq=%v score=%v",
+		game.Moves, game.MaxSeq, game.FinalScore)
+}
+
+// hints and autoplay are not allowed in versus and hardcore mode
+func helpAllowed() bool {
+	return versus == nil && !scoreRules.Hardcore
+}
+
+// undo is not allowed in hardcore mode (and in versus mode only for the current turn)
+func undoAllowed() bool {
+	return !scoreRules.Hardcore && versus.CanUndo(&game)
+}
+
+// start a new game (or resume the saved one)
+func startGame(cw, ch int) {
+	if resumeGame && race == nil && versus == nil {
+		resumeGame = false
+
+		if loadGame(&game, cw, ch) {
+			return
+		}
+	}
+
+	game.Setup(gameWidth, gameHeight, cw, ch)
+	stats.Start(&game)
+}
+
+func terminateMain() {
+	stats.Abandon(&game) // before saving the game, so that the resumed game isn't counted again
+	saveGame(&game)
+	saveScores()
 	os.Exit(0)
 }
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"sort"
+)
+
+// the content of the score file
+type ScoreFile struct {
+	Scores Scores `json:"scores"`
+	Daily  Daily  `json:"daily,omitempty"`
+	Stats  Stats  `json:"stats,omitempty"`
+}
+
+// where scores, statistics and saved games are stored
+var storage = newStorage()
+
+// statistics as they were on disk, so that on save we only add what changed in this session
+var savedStats = Stats{}
+
+// read and decode the score file
+func readScoreFile() (sf ScoreFile, err error) {
+	sf = ScoreFile{Scores: Scores{}, Daily: Daily{}, Stats: Stats{}}
+
+	data, err := storage.Load(scoresObject)
+	if err != nil {
+		return
+	}
+
+	var sections map[string]json.RawMessage
+
+	if err = json.Unmarshal(data, &sections); err != nil {
+		return
+	}
+
+	if _, ok := sections["scores"]; !ok {
+		// old format: only the scoreboard
+		err = json.Unmarshal(data, &sf.Scores)
+		return
+	}
+
+	err = json.Unmarshal(data, &sf)
+	return
+}
+
+// keep a copy of a score file that cannot be decoded
+func backupScoreFile(err error) {
+	backup, berr := storage.Backup(scoresObject)
+	if berr != nil {
+		log.Printf("cannot read %v: %v (backup failed: %v)", storage.Location(scoresObject), err, berr)
+		return
+	}
+
+	log.Printf("cannot read %v: %v (saved as %v)", storage.Location(scoresObject), err, backup)
+}
+
+// read scores from the score file
+func loadScores() {
+	sf, err := readScoreFile()
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			backupScoreFile(err)
+		}
+
+		return
+	}
+
+	scores, daily, stats = sf.Scores, sf.Daily, sf.Stats
+	savedStats = stats.Copy()
+}
+
+// write scores to the score file,
+// merging them with the current content (in case another instance updated it)
+func saveScores() {
+	unlock, err := storage.Lock(scoresObject)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	defer unlock()
+
+	sf, err := readScoreFile()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		backupScoreFile(err)
+	}
+
+	sf.Scores.Merge(scores)
+	sf.Daily.Merge(daily)
+	sf.Stats.Merge(stats, savedStats)
+
+	data, err := json.Marshal(sf)
+	if err == nil {
+		err = storage.Store(scoresObject, append(data, '\n'))
+	}
+
+	if err != nil {
+		log.Printf("cannot write %v: %v", storage.Location(scoresObject), err)
+		return
+	}
+
+	scores, daily, stats = sf.Scores, sf.Daily, sf.Stats
+	savedStats = stats.Copy()
+}
+
+// save the current game, if it's not completed, so that it can be resumed
+// (otherwise the saved game, if any, is kept: it's only removed when it's resumed)
+func saveGame(g *Game) {
+	if g.Width == 0 || g.Completed || g.Over || g.Count == 0 || versus != nil || race != nil {
+		return
+	}
+
+	g.Draws = g.src.n // so that the resumed game shuffles as if it wasn't interrupted
+
+	data, err := json.Marshal(g)
+	if err == nil {
+		err = storage.Store(savegameObject, data)
+	}
+
+	if err != nil {
+		log.Printf("cannot save game to %v: %v", storage.Location(savegameObject), err)
+	}
+}
+
+// restore the saved game (if the board size, parameters and mode match,
+// and for the daily challenge if it's the same board), removing it:
+// the game is saved again on exit if it's not completed
+func loadGame(g *Game, cw, ch int) bool {
+	data, err := storage.Load(savegameObject)
+	if err != nil {
+		return false
+	}
+
+	var saved Game
+
+	if err := json.Unmarshal(data, &saved); err != nil {
+		log.Printf("cannot restore game from %v: %v", storage.Location(savegameObject), err)
+		return false
+	}
+
+	if saved.Params == (BoardParams{}) { // saved before the board parameters were introduced
+		saved.Params = DefaultParams
+	}
+
+	if saved.Width != gameWidth || saved.Height != gameHeight || len(saved.Screen) != saved.Height ||
+		saved.Params != boardParams || saved.Hardcore != scoreRules.Hardcore {
+		return false
+	}
+
+	if dailyDate != "" && saved.Seed != gameSeed { // not the board of the daily challenge
+		return false
+	}
+
+	storage.Delete(savegameObject)
+
+	*g = saved
+	g.cellwidth = cw
+	g.cellheight = ch
+
+	if g.Draws > 0 {
+		g.seedRandom(g.Seed, g.Draws)
+	} else { // saved before the random generator state was saved
+		g.seedRandom(g.Seed+int64(g.Shuffles), 0)
+	}
+
+	return true
+}
+
+// add the entries from other scoreboards, keeping the top 10
+func (sc Scores) Merge(other Scores) {
+	for key, oss := range other {
+		ss := append([]ScoreInfo(nil), sc[key]...)
+
+		for _, si := range oss {
+			found := false
+			for _, s := range ss {
+				if s.Same(si) {
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				ss = append(ss, si)
+			}
+		}
+
+		sort.SliceStable(ss, func(i, j int) bool { return ss[i].Score > ss[j].Score })
+		if len(ss) > 10 {
+			ss = ss[:10]
+		}
+
+		sc[key] = ss
+	}
+}
+
+// add the daily results from other, for the days that are missing
+func (d Daily) Merge(other Daily) {
+	for key, odd := range other {
+		dd := d[key]
+
+		for _, di := range odd {
+			found := false
+			for _, dx := range dd {
+				if dx.Date == di.Date {
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				dd = append(dd, di)
+			}
+		}
+
+		sort.Slice(dd, func(i, j int) bool { return dd[i].Date < dd[j].Date })
+		d[key] = dd
+	}
+}
+
+// add the changes between base and current to the statistics
+func (s Stats) Merge(current, base Stats) {
+	for key, ci := range current {
+		bi := base[key]
+		if bi == nil {
+			bi = &StatInfo{}
+		}
+
+		si := s[key]
+		if si == nil {
+			si = &StatInfo{}
+			s[key] = si
+		}
+
+		si.Started += ci.Started - bi.Started
+		si.Completed += ci.Completed - bi.Completed
+		si.Removed += ci.Removed - bi.Removed
+		si.Moves += ci.Moves - bi.Moves
+		si.Shuffles += ci.Shuffles - bi.Shuffles
+		si.Autoplay += ci.Autoplay - bi.Autoplay
+		si.Manual += ci.Manual - bi.Manual
+
+		if ci.MaxSeq > si.MaxSeq {
+			si.MaxSeq = ci.MaxSeq
+		}
+	}
+}
+
+func (s Stats) Copy() Stats {
+	c := Stats{}
+	for key, si := range s {
+		ci := *si
+		c[key] = &ci
+	}
+
+	return c
+}
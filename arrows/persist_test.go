@@ -0,0 +1,124 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScoresMerge(t *testing.T) {
+	a := ScoreInfo{Moves: 50, MaxSeq: 5, Score: 400, Seed: 1, Time: 100}
+	b := ScoreInfo{Moves: 50, MaxSeq: 5, Score: 400, Seed: 2, Time: 200} // a different game with the same result
+	old := ScoreInfo{Moves: 60, MaxSeq: 3, Score: 300}                   // saved before seed and time were recorded
+
+	sc := Scores{"20020": {a, old}}
+
+	// the same game saved again (with a difficulty rating) isn't duplicated
+	again := a
+	again.Difficulty = Hard
+
+	sc.Merge(Scores{"20020": {again, b, old}})
+
+	if got := sc["20020"]; len(got) != 3 || !got[0].Same(a) || !got[1].Same(b) || got[2] != old {
+		t.Errorf("merged scores: %+v", got)
+	}
+}
+
+func TestResumeGame(t *testing.T) {
+	defer func(st Storage) { storage = st }(storage)
+	storage = newMemStorage()
+
+	gameWidth, gameHeight, boardParams, scoreRules = 12, 10, DefaultParams, ClassicRules
+
+	var g Game
+	g.SetupSeed(gameWidth, gameHeight, 1, 1, 77)
+	g.Shuffle(ShuffleRandom)
+	g.PlayTurn()
+
+	saveGame(&g)
+
+	var resumed Game
+	if !loadGame(&resumed, 1, 1) {
+		t.Fatal("game not restored")
+	}
+
+	// the resumed game shuffles as the uninterrupted one
+	if !reflect.DeepEqual(shuffleBoards(&resumed, 5), shuffleBoards(&g, 5)) {
+		t.Error("the resumed game shuffles differently")
+	}
+
+	// the saved game is removed when it's resumed
+	if loadGame(&resumed, 1, 1) {
+		t.Error("game restored twice")
+	}
+
+	// and it's not replaced by a completed game, or a game that cannot be resumed
+	saveGame(&g)
+
+	defer func() { race, versus = nil, nil }()
+
+	for _, other := range []func(g *Game){
+		func(g *Game) { g.Completed = true },
+		func(g *Game) { g.Width = 0 },
+		func(g *Game) { versus = newVersus(2) },
+		func(g *Game) { race = &Race{} },
+	} {
+		c := g.Clone()
+		other(c)
+		saveGame(c)
+		race, versus = nil, nil
+	}
+
+	if !loadGame(&resumed, 1, 1) || resumed.Seed != g.Seed {
+		t.Error("saved game removed")
+	}
+}
+
+func TestResumeStats(t *testing.T) {
+	defer func(st Storage, s Stats) { storage, stats = st, s }(storage, stats)
+	storage = newMemStorage()
+
+	gameWidth, gameHeight, boardParams, scoreRules = 12, 10, DefaultParams, ClassicRules
+
+	// play a game until it's completed, quitting and resuming it after each turn
+	var g, ref Game
+	g.SetupSeed(gameWidth, gameHeight, 1, 1, 77)
+	ref.SetupSeed(gameWidth, gameHeight, 1, 1, 77)
+
+	stats = Stats{}
+	stats.Start(&g)
+
+	for {
+		if g.PlayTurn() == None {
+			g.Shuffle(ShuffleRandom)
+		}
+
+		if g.Count == 0 {
+			break
+		}
+
+		stats.Abandon(&g)
+		saveGame(&g)
+
+		if !loadGame(&g, 1, 1) {
+			t.Fatal("game not restored")
+		}
+	}
+
+	stats.Complete(&g)
+
+	// the same game, without interruptions
+	want := Stats{}
+	want.Start(&ref)
+
+	for ref.Count > 0 {
+		if ref.PlayTurn() == None {
+			ref.Shuffle(ShuffleRandom)
+		}
+	}
+
+	want.Complete(&ref)
+
+	if got := *stats.get(&g); got != *want.get(&ref) || got.Removed != ref.Removed || got.Shuffles != ref.Shuffles {
+		t.Errorf("resumed game stats %+v, want %+v", got, *want.get(&ref))
+	}
+}
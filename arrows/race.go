@@ -0,0 +1,415 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Race protocol
+//
+// All messages are JSON objects, one per line.
+//
+//	join   (player -> host): {"type":"join","name":"bob"}
+//	start  (host -> player): {"type":"start","seed":12345,"width":20,"height":20,"params":{...},"difficulty":"hard"}
+//	                         (the seed of the board chosen by the host for the difficulty, the players don't regenerate it)
+//	status (player -> host): {"type":"status","name":"bob","remain":120,"score":350}
+//	status (host -> all):    same as above, relayed to all players (including the host own status)
+//	win    (host -> all):    {"type":"win","name":"bob"}
+//	leave  (host -> all):    {"type":"leave","name":"bob"}
+//
+// All players play the same board (same seed, size, generation parameters and difficulty), the first player that reports
+// a status with "remain":0 wins the race. Player names should be unique.
+type RaceMsg struct {
+	Type   string `json:"type"`
+	Name   string `json:"name,omitempty"`
+	Seed   int64  `json:"seed,omitempty"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+	Remain int    `json:"remain"`
+	Score  int    `json:"score"`
+
+	Params     *BoardParams `json:"params,omitempty"`     // board generation parameters (only in "start")
+	Difficulty string       `json:"difficulty,omitempty"` // requested difficulty (only in "start")
+}
+
+// messages queued for a player, a player that falls behind is disconnected
+const peerQueue = 64
+
+type Race struct {
+	sync.Mutex
+
+	Name   string
+	Winner string
+	Addr   net.Addr // the address the host is listening on
+
+	onUpdate func() // called when the status of other players changes
+
+	host    bool
+	start   RaceMsg // the board sent to the players (host only)
+	last    RaceMsg
+	players map[string]RaceMsg
+	conns   map[net.Conn]*racePeer
+	changed bool
+}
+
+// a connection to another player, with its own goroutine sending the messages
+// (so that a slow player doesn't block the game)
+type racePeer struct {
+	conn net.Conn
+	out  chan RaceMsg
+}
+
+var race *Race
+
+func newRace(name string) *Race {
+	return &Race{
+		Name:    name,
+		players: map[string]RaceMsg{},
+		conns:   map[net.Conn]*racePeer{},
+	}
+}
+
+// start a race as the host, listening for other players on addr
+func hostRace(addr, name string) (*Race, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if gameSeed == 0 { // all players need to use the same board
+		gameSeed = time.Now().UnixNano()
+	}
+
+	if gameDifficulty != "" && !seedRated { // choose the board now, so that the players don't have to
+		var g Game
+		g.SetupSeed(gameWidth+2, gameHeight+2, 1, 1, gameSeed) // with the border (added later)
+		g.regenerate(gameDifficulty)
+
+		gameSeed, seedRated = g.Seed, true
+	}
+
+	r := newRace(name)
+	r.host = true
+	r.Addr = l.Addr()
+	params := boardParams
+	r.start = RaceMsg{Type: "start", Seed: gameSeed, Width: gameWidth, Height: gameHeight, Params: &params, Difficulty: gameDifficulty}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				log.Println(err)
+				return
+			}
+
+			go r.serve(conn)
+		}
+	}()
+
+	return r, nil
+}
+
+// join a race hosted at addr.
+// this sets the board seed and size to the ones used by the host.
+func joinRace(addr, name string) (*Race, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	enc := json.NewEncoder(conn)
+	if err := enc.Encode(RaceMsg{Type: "join", Name: name}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(conn)
+
+	var msg RaceMsg
+
+	if !scanner.Scan() {
+		conn.Close()
+		return nil, fmt.Errorf("no response from %v", addr)
+	}
+
+	if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil || msg.Type != "start" {
+		conn.Close()
+		return nil, fmt.Errorf("invalid response from %v: %q", addr, scanner.Text())
+	}
+
+	gameSeed = msg.Seed
+	gameWidth = msg.Width
+	gameHeight = msg.Height
+
+	boardParams = DefaultParams
+	if msg.Params != nil {
+		boardParams = *msg.Params
+	}
+
+	gameDifficulty = msg.Difficulty
+	seedRated = gameDifficulty != "" // the host already chose a board at this difficulty
+
+	r := newRace(name)
+	r.addPeer(conn)
+
+	go func() {
+		for scanner.Scan() {
+			if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+				log.Printf("invalid message from %v: %q", addr, scanner.Text())
+				continue
+			}
+
+			r.handle(msg)
+		}
+
+		r.Lock()
+		r.removePeer(conn)
+		r.Winner = ""
+		r.players = map[string]RaceMsg{}
+		r.changed = true
+		r.Unlock()
+		r.notify()
+	}()
+
+	return r, nil
+}
+
+// handle a connection from a player (host only)
+func (r *Race) serve(conn net.Conn) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	enc := json.NewEncoder(conn)
+
+	var msg RaceMsg
+
+	if !scanner.Scan() {
+		return
+	}
+
+	if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil || msg.Type != "join" || msg.Name == "" {
+		return
+	}
+
+	name := msg.Name
+
+	if err := enc.Encode(r.start); err != nil {
+		return
+	}
+
+	r.Lock()
+	peer := r.addPeer(conn)
+
+	// send the current status of all players to the new player
+	if r.last.Type != "" {
+		peer.send(r.last)
+	}
+	for _, p := range r.players {
+		peer.send(p)
+	}
+	if r.Winner != "" {
+		peer.send(RaceMsg{Type: "win", Name: r.Winner})
+	}
+	r.Unlock()
+
+	for scanner.Scan() {
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			continue
+		}
+
+		if msg.Type == "status" {
+			msg.Name = name
+			r.handle(msg)
+		}
+	}
+
+	r.Lock()
+	r.removePeer(conn)
+	delete(r.players, name)
+	r.changed = true
+	r.broadcast(RaceMsg{Type: "leave", Name: name})
+	r.Unlock()
+	r.notify()
+}
+
+// process a message from the network
+func (r *Race) handle(msg RaceMsg) {
+	r.Lock()
+
+	switch msg.Type {
+	case "status":
+		if msg.Name != r.Name {
+			r.players[msg.Name] = msg
+		}
+
+		if r.host {
+			r.broadcast(msg)
+			r.checkWinner(msg)
+		}
+
+	case "win":
+		r.Winner = msg.Name
+
+	case "leave":
+		delete(r.players, msg.Name)
+	}
+
+	r.changed = true
+	r.Unlock()
+	r.notify()
+}
+
+// add a connection, and start sending the queued messages (should be called with the lock held)
+func (r *Race) addPeer(conn net.Conn) *racePeer {
+	p := &racePeer{conn: conn, out: make(chan RaceMsg, peerQueue)}
+	r.conns[conn] = p
+
+	go func() {
+		enc := json.NewEncoder(conn)
+
+		for msg := range p.out {
+			if err := enc.Encode(msg); err != nil {
+				conn.Close()
+				return
+			}
+		}
+	}()
+
+	return p
+}
+
+// remove a connection, once it's closed (should be called with the lock held)
+func (r *Race) removePeer(conn net.Conn) {
+	if p, ok := r.conns[conn]; ok {
+		delete(r.conns, conn)
+		close(p.out)
+	}
+}
+
+// queue a message for the player, or disconnect the player if it's not reading them
+func (p *racePeer) send(msg RaceMsg) {
+	select {
+	case p.out <- msg:
+	default:
+		p.conn.Close()
+	}
+}
+
+// send message to all connections (should be called with the lock held)
+func (r *Race) broadcast(msg RaceMsg) {
+	for _, p := range r.conns {
+		p.send(msg)
+	}
+}
+
+// the first player to clear the board wins (host only, should be called with the lock held)
+func (r *Race) checkWinner(msg RaceMsg) {
+	if r.Winner == "" && msg.Remain == 0 {
+		r.Winner = msg.Name
+		r.broadcast(RaceMsg{Type: "win", Name: msg.Name})
+	}
+}
+
+// set the function called when the status of other players changes
+func (r *Race) SetOnUpdate(fn func()) {
+	if r == nil {
+		return
+	}
+
+	r.Lock()
+	r.onUpdate = fn
+	r.Unlock()
+}
+
+func (r *Race) notify() {
+	r.Lock()
+	fn := r.onUpdate
+	r.Unlock()
+
+	if fn != nil {
+		fn()
+	}
+}
+
+// send the current game status to the other players (if it changed)
+func (r *Race) Report(g *Game) {
+	if r == nil {
+		return
+	}
+
+	msg := RaceMsg{Type: "status", Name: r.Name, Remain: g.Count, Score: g.Score}
+	if g.Completed {
+		msg.Remain = 0
+		msg.Score = g.FinalScore
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	if msg == r.last {
+		return
+	}
+
+	r.last = msg
+	r.broadcast(msg)
+
+	if r.host {
+		r.checkWinner(msg)
+	}
+}
+
+// return true if the status of other players changed since the last call
+func (r *Race) Changed() bool {
+	if r == nil {
+		return false
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	changed := r.changed
+	r.changed = false
+	return changed
+}
+
+// return a short description of the race status (other players remaining arrows and score)
+func (r *Race) Status() string {
+	if r == nil {
+		return ""
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	var names []string
+	for name := range r.players {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var parts []string
+
+	if r.Winner == r.Name {
+		parts = append(parts, "you won!")
+	} else if r.Winner != "" {
+		parts = append(parts, r.Winner+" won!")
+	}
+
+	for _, name := range names {
+		p := r.players[name]
+		parts = append(parts, fmt.Sprintf("%v=%v/%v", name, p.Remain, p.Score))
+	}
+
+	if len(parts) == 0 {
+		return "waiting for players"
+	}
+
+	return strings.Join(parts, " ")
+}
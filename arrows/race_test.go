@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wait until cond is true (or fail the test)
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+
+	t.Fatalf("timeout waiting for %v", what)
+}
+
+func TestRaceLoopback(t *testing.T) {
+	gameSeed, gameWidth, gameHeight = 1234, 10, 8 // the border is added after hosting
+	boardParams = DefaultParams
+	gameDifficulty = ""
+
+	host, err := hostRace("127.0.0.1:0", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updates := make(chan struct{}, 100)
+	host.SetOnUpdate(func() { updates <- struct{}{} })
+
+	bob, err := joinRace(host.Addr.String(), "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the player gets the host board
+	if gameSeed != 1234 || gameWidth != 10 || gameHeight != 8 {
+		t.Fatalf("wrong board from host: seed=%v size=%vx%v", gameSeed, gameWidth, gameHeight)
+	}
+
+	var g Game
+	g.SetupSeed(12, 10, 1, 1, 1234)
+
+	bob.Report(&g)
+	waitFor(t, "bob status", func() bool { return strings.Contains(host.Status(), "bob=80/0") })
+
+	select {
+	case <-updates:
+	default:
+		t.Error("host not notified")
+	}
+
+	host.Report(&g)
+	waitFor(t, "alice status", func() bool { return strings.Contains(bob.Status(), "alice=80/0") })
+
+	g.Completed, g.FinalScore = true, 500
+	bob.Report(&g)
+	waitFor(t, "winner", func() bool { return bob.Status() == "you won! alice=80/0" })
+
+	if host.Winner != "bob" {
+		t.Errorf("host winner=%q, want bob", host.Winner)
+	}
+}
+
+func TestRaceStalledPlayer(t *testing.T) {
+	gameSeed, gameWidth, gameHeight = 1234, 12, 10
+
+	host, err := hostRace("127.0.0.1:0", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a player that joins and never reads
+	conn, err := net.Dial("tcp", host.Addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	json.NewEncoder(conn).Encode(RaceMsg{Type: "join", Name: "stalled"})
+	waitFor(t, "stalled player", func() bool {
+		host.Lock()
+		defer host.Unlock()
+		return len(host.conns) == 1
+	})
+
+	// enough messages to fill the network buffers, that shouldn't block the host
+	done := make(chan struct{})
+
+	go func() {
+		var g Game
+		g.SetupSeed(12, 10, 1, 1, 1234)
+
+		for i := 0; i < 1000000; i++ {
+			g.Count = i
+			host.Report(&g)
+		}
+
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("host blocked by a stalled player")
+	}
+}
+
+func TestRaceDifficulty(t *testing.T) {
+	defer func() { gameDifficulty, seedRated = "", false }()
+
+	gameSeed, gameWidth, gameHeight = 1234, 10, 8 // the border is added after hosting
+	boardParams = DefaultParams
+	gameDifficulty, seedRated = Hard, false
+
+	host, err := hostRace("127.0.0.1:0", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chosen := gameSeed
+	if !seedRated {
+		t.Fatal("the host didn't choose the board")
+	}
+
+	var want Game
+	want.SetupSeed(12, 10, 1, 1, 1234)
+	want.regenerate(Hard)
+
+	if chosen != want.Seed {
+		t.Fatalf("host chose seed %v, want %v", chosen, want.Seed)
+	}
+
+	gameSeed, seedRated = 0, false
+
+	if _, err := joinRace(host.Addr.String(), "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	if gameSeed != chosen || gameDifficulty != Hard || !seedRated {
+		t.Fatalf("player got seed=%v difficulty=%q rated=%v", gameSeed, gameDifficulty, seedRated)
+	}
+
+	// the player plays the chosen board, without regenerating it
+	var g Game
+	g.Setup(12, 10, 1, 1)
+
+	if g.Seed != chosen {
+		t.Errorf("player board seed %v, want %v", g.Seed, chosen)
+	}
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"log"
+	"os"
+
+	_ "embed"
+
+	"github.com/disintegration/imaging"
+)
+
+var (
+	//go:embed assets/up-arrow.png
+	pngUp []byte
+
+	//go:embed assets/dot.png
+	pngDot []byte
+
+	bgColor = color.NRGBA{0, 0, 32, 255}
+
+	// preview colors, for the arrows and the path they would follow
+	previewColors = map[Updates]color.NRGBA{
+		Remove: {0, 200, 0, 96},
+		Move:   {200, 200, 0, 96},
+		None:   {200, 0, 0, 96},
+	}
+
+	// minimap colors, for the background, the blocks with arrows and the outline of the visible part
+	mapColor     = color.NRGBA{0, 0, 0, 160}
+	mapArrow     = color.NRGBA{160, 160, 200, 255}
+	mapViewColor = color.NRGBA{255, 255, 0, 255}
+
+	hardcoreColor = color.NRGBA{220, 0, 0, 255} // the frame around the board in hardcore mode
+
+	// the cursor frame: a thick bright outline, with a dark edge inside to make it visible on any cell
+	cursorColor = color.NRGBA{255, 255, 255, 255}
+	cursorEdge  = color.NRGBA{0, 0, 0, 255}
+
+	gDirs [5]image.Image
+	gDot  image.Image
+	cell  image.Point
+)
+
+const (
+	gifDelay    = 25 // delay between animation frames, in 100ths of a second
+	cursorFrame = 6  // width of the cursor frame (image pixels)
+	cursorInner = 2  // width of the dark edge inside the cursor frame
+)
+
+// decode the arrow images (the size of the images is the size of a board cell)
+func loadImages() {
+	if gDot != nil {
+		return
+	}
+
+	if img, err := png.Decode(bytes.NewBuffer(pngUp)); err != nil {
+		log.Fatal(err)
+	} else {
+		cell = img.Bounds().Size()
+
+		gDirs[Empty] = imaging.New(cell.X, cell.Y, bgColor)
+		gDirs[Up] = img
+		gDirs[Left] = imaging.Rotate90(gDirs[Up])
+		gDirs[Down] = imaging.Rotate90(gDirs[Left])
+		gDirs[Right] = imaging.Rotate90(gDirs[Down])
+	}
+
+	if img, err := png.Decode(bytes.NewBuffer(pngDot)); err != nil {
+		log.Fatal(err)
+	} else {
+		gDot = img
+	}
+}
+
+// return an image that can contain the rendered board
+func newCanvas(screen [][]Dir) draw.Image {
+	loadImages()
+
+	w := 0
+	if len(screen) > 0 {
+		w = len(screen[0])
+	}
+
+	return imaging.New(w*cell.X, len(screen)*cell.Y, bgColor)
+}
+
+// return the rectangle (in cells) of the whole board
+func boardRect(screen [][]Dir) image.Rectangle {
+	if len(screen) == 0 {
+		return image.Rectangle{}
+	}
+
+	return image.Rect(0, 0, len(screen[0]), len(screen))
+}
+
+// draw the cells of the board in view (the top-left cell in view is drawn at 0,0 on canvas),
+// or all dots if the game is over
+func renderBoard(canvas draw.Image, screen [][]Dir, view image.Rectangle, dotscreen bool) {
+	loadImages()
+
+	draw.Draw(canvas, canvas.Bounds(), &image.Uniform{bgColor}, image.Point{}, draw.Src)
+
+	view = view.Intersect(boardRect(screen))
+
+	for y := view.Min.Y; y < view.Max.Y; y++ {
+		for x := view.Min.X; x < view.Max.X; x++ {
+			im := gDirs[screen[y][x]]
+
+			if dotscreen {
+				im = gDot
+			}
+
+			draw.Draw(canvas,
+				im.Bounds().Add(image.Point{(x - view.Min.X) * cell.X, (y - view.Min.Y) * cell.Y}),
+				im, image.Point{}, draw.Over)
+		}
+	}
+}
+
+// draw the cursor frame around cell px,py (view is the part of the board drawn on canvas, as in renderBoard),
+// with a dot if the cell is empty
+func renderCursor(canvas draw.Image, screen [][]Dir, view image.Rectangle, px, py int) {
+	if !image.Pt(px, py).In(view.Intersect(boardRect(screen))) {
+		return
+	}
+
+	r := image.Rect(0, 0, cell.X, cell.Y).Add(image.Point{(px - view.Min.X) * cell.X, (py - view.Min.Y) * cell.Y})
+
+	if screen[py][px] == Empty {
+		draw.Draw(canvas, r, gDot, image.Point{}, draw.Over)
+	}
+
+	drawFrame(canvas, r, cursorFrame, cursorColor)
+	drawFrame(canvas, r.Inset(cursorFrame), cursorInner, cursorEdge)
+}
+
+// draw a frame of width w inside r
+func drawFrame(canvas draw.Image, r image.Rectangle, w int, c color.Color) {
+	u := &image.Uniform{c}
+
+	draw.Draw(canvas, image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+w), u, image.Point{}, draw.Src)
+	draw.Draw(canvas, image.Rect(r.Min.X, r.Max.Y-w, r.Max.X, r.Max.Y), u, image.Point{}, draw.Src)
+	draw.Draw(canvas, image.Rect(r.Min.X, r.Min.Y, r.Min.X+w, r.Max.Y), u, image.Point{}, draw.Src)
+	draw.Draw(canvas, image.Rect(r.Max.X-w, r.Min.Y, r.Max.X, r.Max.Y), u, image.Point{}, draw.Src)
+}
+
+// highlight the arrows that would move by clicking at px,py and the cells they would go through
+// (view is the part of the board drawn on canvas, as in renderBoard)
+func renderPreview(canvas draw.Image, g *Game, view image.Rectangle, px, py int) {
+	cells, empty, res := g.Preview(g.ScreenCoords(0, 0, px, py))
+	if res == Invalid {
+		return
+	}
+
+	c := previewColors[res]
+
+	for _, cl := range cells {
+		draw.Draw(canvas,
+			image.Rect(0, 0, cell.X, cell.Y).Add(image.Point{(cl.X - view.Min.X) * cell.X, (cl.Y - view.Min.Y) * cell.Y}),
+			&image.Uniform{c}, image.Point{}, draw.Over)
+	}
+
+	c.A /= 2 // lighter path
+
+	for _, cl := range empty {
+		draw.Draw(canvas,
+			image.Rect(0, 0, cell.X, cell.Y).Add(image.Point{(cl.X - view.Min.X) * cell.X, (cl.Y - view.Min.Y) * cell.Y}),
+			&image.Uniform{c}, image.Point{}, draw.Over)
+	}
+}
+
+// return a map of the whole board, at most mw x mh: each point of the map is a (square) block of cells
+// and it's true if there are arrows in the block. The view (in cells) is converted to map coordinates.
+func minimap(screen [][]Dir, mw, mh int, view image.Rectangle) (blocks [][]bool, mview image.Rectangle) {
+	board := boardRect(screen)
+	if board.Empty() || mw <= 0 || mh <= 0 {
+		return nil, image.Rectangle{}
+	}
+
+	// size of a block, in cells
+	b := (board.Dx() + mw - 1) / mw
+	if bh := (board.Dy() + mh - 1) / mh; bh > b {
+		b = bh
+	}
+
+	blocks = make([][]bool, (board.Dy()+b-1)/b)
+	for i := range blocks {
+		blocks[i] = make([]bool, (board.Dx()+b-1)/b)
+	}
+
+	for y, row := range screen {
+		for x, col := range row {
+			if col != Empty {
+				blocks[y/b][x/b] = true
+			}
+		}
+	}
+
+	view = view.Intersect(board)
+	mview = image.Rect(view.Min.X/b, view.Min.Y/b, (view.Max.X+b-1)/b, (view.Max.Y+b-1)/b)
+	return
+}
+
+// draw the map of the whole board (at most size x size pixels), with the outline of the cells in view
+func renderMinimap(screen [][]Dir, view image.Rectangle, size int) *image.NRGBA {
+	blocks, mview := minimap(screen, size, size, view)
+	if blocks == nil {
+		return nil
+	}
+
+	// size of a block, in pixels
+	k := size / len(blocks)
+	if kw := size / len(blocks[0]); kw < k {
+		k = kw
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, len(blocks[0])*k, len(blocks)*k))
+	draw.Draw(img, img.Bounds(), &image.Uniform{mapColor}, image.Point{}, draw.Src)
+
+	for y, row := range blocks {
+		for x, b := range row {
+			if b {
+				draw.Draw(img, image.Rect(x*k, y*k, (x+1)*k, (y+1)*k), &image.Uniform{mapArrow}, image.Point{}, draw.Src)
+			}
+		}
+	}
+
+	drawFrame(img, image.Rect(mview.Min.X*k, mview.Min.Y*k, mview.Max.X*k, mview.Max.Y*k), 1, mapViewColor)
+	return img
+}
+
+// return a copy of the board
+func copyScreen(screen [][]Dir) [][]Dir {
+	c := make([][]Dir, len(screen))
+	for y, row := range screen {
+		c[y] = append([]Dir(nil), row...)
+	}
+
+	return c
+}
+
+// return the boards played so far, from the last shuffle (or the start of the game) to the current one
+func replayFrames(g *Game) (frames [][][]Dir) {
+	c := g.Clone()
+	frames = append(frames, copyScreen(c.Screen))
+
+	for {
+		if _, _, ok := c.Undo(); !ok {
+			break
+		}
+
+		frames = append(frames, copyScreen(c.Screen))
+	}
+
+	for i, j := 0, len(frames)-1; i < j; i, j = i+1, j-1 {
+		frames[i], frames[j] = frames[j], frames[i]
+	}
+
+	return
+}
+
+// autoplay the game, returning the boards after each turn and shuffle
+func autoplayFrames(g *Game) (frames [][][]Dir) {
+	frames = append(frames, copyScreen(g.Screen))
+	shuffles := 0
+
+	for g.Count > 0 {
+		if g.PlayTurn() > None {
+			g.Seq = 0
+		} else if shuffles < maxShuffles {
+			g.Shuffle(shuffleMode)
+			shuffles++
+		} else {
+			break
+		}
+
+		frames = append(frames, copyScreen(g.Screen))
+	}
+
+	return
+}
+
+// save the board as a PNG image
+func saveSnapshot(filename string, screen [][]Dir) error {
+	canvas := newCanvas(screen)
+	renderBoard(canvas, screen, boardRect(screen), false)
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	if err := png.Encode(f, canvas); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// save the boards as an animated GIF (the last frame is held a little longer)
+func saveGIF(filename string, frames [][][]Dir) error {
+	if len(frames) == 0 {
+		return fmt.Errorf("no frames to save")
+	}
+
+	canvas := newCanvas(frames[0])
+	anim := gif.GIF{}
+
+	for i, screen := range frames {
+		renderBoard(canvas, screen, boardRect(screen), false)
+
+		frame := image.NewPaletted(canvas.Bounds(), palette.Plan9)
+		draw.Draw(frame, frame.Bounds(), canvas, image.Point{}, draw.Src)
+
+		delay := gifDelay
+		if i == len(frames)-1 {
+			delay *= 8
+		}
+
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, delay)
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	if err := gif.EncodeAll(f, &anim); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// the name of the file for a snapshot or replay saved while playing
+func captureName(g *Game, ext string) string {
+	return fmt.Sprintf("arrows-%v-%v.%v", g.Seed, g.Moves, ext)
+}
+
+// render the board without a UI: save a snapshot of the board and/or the animation of an autoplay run
+func headless(snapshot, gifname string) error {
+	var g Game
+
+	if !resumeGame || !loadGame(&g, 1, 1) {
+		g.Setup(gameWidth, gameHeight, 1, 1)
+	}
+
+	if snapshot != "" {
+		if err := saveSnapshot(snapshot, g.Screen); err != nil {
+			return err
+		}
+	}
+
+	if gifname != "" {
+		if err := saveGIF(gifname, autoplayFrames(&g)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
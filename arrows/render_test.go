@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// the expected renderings of the test board (hashes of the pixels, so that they don't depend on the encoder)
+const (
+	goldenSnapshot = "0b1daf4f1c7bcd2f2fc5b8df57e61f3f803402e12d59277393f89f6f89607c45"
+	goldenGIF      = "d51c5d71864bd1e00fcce83da6d1a839763ab47f9ec26cd5aedac0eb031aa648"
+)
+
+// a small board, with a few moves played and arrows left
+func renderGame(t *testing.T) *Game {
+	var g Game
+
+	g.SetupSeed(7, 6, 1, 1, 22)
+	g.PlayTurn()
+	g.Shuffle(ShuffleRandom)
+	g.PlayTurn()
+
+	if g.Count == 0 || g.StackSize() < 2 {
+		t.Fatalf("test board with %v arrows and %v moves", g.Count, g.StackSize())
+	}
+
+	return &g
+}
+
+func hashImage(img image.Image) string {
+	rgba := image.NewNRGBA(img.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	return fmt.Sprintf("%x", sha256.Sum256(rgba.Pix))
+}
+
+func TestSnapshotGolden(t *testing.T) {
+	g := renderGame(t)
+	filename := filepath.Join(t.TempDir(), "snapshot.png")
+
+	if err := saveSnapshot(filename, g.Screen); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if size := img.Bounds().Size(); size != image.Pt(7*cell.X, 6*cell.Y) {
+		t.Errorf("snapshot size %v", size)
+	}
+
+	if h := hashImage(img); h != goldenSnapshot {
+		t.Errorf("snapshot hash %v, want %v", h, goldenSnapshot)
+	}
+}
+
+func TestReplayGolden(t *testing.T) {
+	g := renderGame(t)
+
+	frames := replayFrames(g)
+	if len(frames) != g.StackSize()+1 || !reflect.DeepEqual(frames[len(frames)-1], g.Screen) {
+		t.Fatalf("replay doesn't end with the current board (%v frames)", len(frames))
+	}
+
+	// saving the replay doesn't change the game: it's the same as a game that was never replayed
+	same := renderGame(t)
+
+	if !reflect.DeepEqual(g.Screen, same.Screen) || g.Count != same.Count || g.Moves != same.Moves ||
+		g.StackSize() != same.StackSize() {
+		t.Error("saving the replay changed the board")
+	}
+
+	if !reflect.DeepEqual(shuffleBoards(g, 3), shuffleBoards(same, 3)) {
+		t.Error("saving the replay changed the shuffles")
+	}
+
+	filename := filepath.Join(t.TempDir(), "replay.gif")
+
+	if err := saveGIF(filename, frames); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	anim, err := gif.DecodeAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(anim.Delay) != len(frames) || anim.Delay[0] != gifDelay || anim.Delay[len(frames)-1] != 8*gifDelay {
+		t.Errorf("delays %v", anim.Delay)
+	}
+
+	hash := sha256.New()
+	for _, frame := range anim.Image {
+		hash.Write([]byte(hashImage(frame)))
+	}
+
+	if h := fmt.Sprintf("%x", hash.Sum(nil)); h != goldenGIF {
+		t.Errorf("replay hash %v, want %v", h, goldenGIF)
+	}
+}
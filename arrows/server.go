@@ -0,0 +1,363 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Game API
+//
+// All requests and responses are JSON objects. Each game played through the API is a session,
+// identified by the "id" returned when the game is created. Cell coordinates are 0-based,
+// x from 0 to width-1 and y from 0 to height-1 (the board border is not included).
+//
+//	POST   /games                {"width":20,"height":20,"seed":12345}  create a game (all fields are optional)
+//	GET    /games/{id}                                                  game state
+//	POST   /games/{id}/update    {"x":3,"y":5,"op":"move"}              move/remove the arrow at x,y ("op" is "move" or "remove")
+//	POST   /games/{id}/shuffle   {"dir":"left"}                         shuffle the arrows ("dir" is "random", "left", "right",
+//	                                                                    "flip", "blocked" or "minimal")
+//	POST   /games/{id}/undo                                             undo the last move (not after the game is completed)
+//	GET    /games/{id}/score                                            score breakdown
+//	DELETE /games/{id}                                                  end the session
+//
+// All requests that change the game return the new game state (for "update" it includes the result:
+// "remove", "move", "none" or "invalid"). Errors are returned as {"error":"..."} with a 4xx status.
+// Games played through the API are not recorded in the scoreboard or statistics.
+
+const (
+	maxSessions    = 1000
+	sessionTimeout = time.Hour // sessions idle for longer than this are removed
+)
+
+var updateNames = map[Updates]string{
+	Invalid: "invalid",
+	None:    "none",
+	Move:    "move",
+	Remove:  "remove",
+}
+
+// the characters used to describe the board
+var gridChars = map[Dir]byte{
+	Empty: '.',
+	Up:    '^',
+	Down:  'v',
+	Left:  '<',
+	Right: '>',
+}
+
+type GameRequest struct {
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Seed   int64  `json:"seed"`
+	X      int    `json:"x"`
+	Y      int    `json:"y"`
+	Op     string `json:"op"`
+	Dir    string `json:"dir"`
+}
+
+type GameState struct {
+	ID        string   `json:"id"`
+	Width     int      `json:"width"`
+	Height    int      `json:"height"`
+	Seed      int64    `json:"seed"`
+	Grid      []string `json:"grid"`
+	Remain    int      `json:"remain"`
+	Free      int      `json:"free"`
+	Removed   int      `json:"removed"`
+	Moves     int      `json:"moves"`
+	Seq       int      `json:"seq"`
+	MaxSeq    int      `json:"maxseq"`
+	Score     int      `json:"score"`
+	Shuffles  int      `json:"shuffles"`
+	Completed bool     `json:"completed"`
+	Result    string   `json:"result,omitempty"`
+}
+
+// a game played through the API
+type Session struct {
+	sync.Mutex
+
+	ID   string
+	Game Game
+
+	lastUsed time.Time
+}
+
+type Server struct {
+	sync.Mutex
+
+	sessions map[string]*Session
+}
+
+func newServer() *Server {
+	return &Server{sessions: map[string]*Session{}}
+}
+
+// serve the game API on addr (this only returns on error)
+func serveAPI(addr string) error {
+	log.Println("serving the game API on", addr)
+	return http.ListenAndServe(addr, newServer().Handler())
+}
+
+func (sv *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/games", sv.handleCreate)
+	mux.HandleFunc("/games/", sv.handleGame)
+	return mux
+}
+
+// return the encoded game state (the session must be locked)
+func (s *Session) state(result string) GameState {
+	g := &s.Game
+
+	st := GameState{
+		ID:        s.ID,
+		Width:     g.Width - 2,
+		Height:    g.Height - 2,
+		Seed:      g.Seed,
+		Remain:    g.Count,
+		Free:      g.Free(),
+		Removed:   g.Removed,
+		Moves:     g.Moves,
+		Seq:       g.Seq,
+		MaxSeq:    g.MaxSeq,
+		Score:     g.ComputeScore(),
+		Shuffles:  g.Shuffles,
+		Completed: g.Completed,
+		Result:    result,
+	}
+
+	for _, row := range g.Screen[1 : g.Height-1] {
+		line := make([]byte, 0, g.Width-2)
+		for _, col := range row[1 : g.Width-1] {
+			line = append(line, gridChars[col])
+		}
+
+		st.Grid = append(st.Grid, string(line))
+	}
+
+	return st
+}
+
+func (sv *Server) newSession(req GameRequest) (*Session, error) {
+	if req.Width == 0 {
+		req.Width = gameWidth
+	}
+
+	if req.Height == 0 {
+		req.Height = gameHeight
+	}
+
+	if req.Width <= 0 || req.Height <= 0 || req.Width > 200 || req.Height > 200 {
+		return nil, fmt.Errorf("invalid width or height")
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	s := &Session{ID: hex.EncodeToString(id), lastUsed: time.Now()}
+	s.Game.SetupSeed(req.Width+2, req.Height+2, 1, 1, req.Seed)
+
+	sv.Lock()
+	defer sv.Unlock()
+
+	for id, other := range sv.sessions {
+		other.Lock()
+		if time.Since(other.lastUsed) > sessionTimeout {
+			delete(sv.sessions, id)
+		}
+		other.Unlock()
+	}
+
+	if len(sv.sessions) >= maxSessions {
+		return nil, fmt.Errorf("too many sessions")
+	}
+
+	sv.sessions[s.ID] = s
+	return s, nil
+}
+
+func (sv *Server) session(id string) *Session {
+	sv.Lock()
+	defer sv.Unlock()
+	return sv.sessions[id]
+}
+
+func (sv *Server) handleCreate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "use POST to create a game")
+		return
+	}
+
+	var req GameRequest
+	if !readRequest(w, r, &req) {
+		return
+	}
+
+	s, err := sv.newSession(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	writeJSON(w, http.StatusCreated, s.state(""))
+}
+
+// handle /games/{id} and /games/{id}/{action}
+func (sv *Server) handleGame(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/games/"), "/")
+	if len(parts) > 2 {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	id, action := parts[0], ""
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+
+	s := sv.session(id)
+	if s == nil {
+		writeError(w, http.StatusNotFound, "no such game")
+		return
+	}
+
+	method := map[string]string{
+		"":        http.MethodGet,
+		"score":   http.MethodGet,
+		"update":  http.MethodPost,
+		"shuffle": http.MethodPost,
+		"undo":    http.MethodPost,
+	}[action]
+
+	if action == "" && r.Method == http.MethodDelete {
+		sv.Lock()
+		delete(sv.sessions, id)
+		sv.Unlock()
+
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if method == "" {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	if r.Method != method {
+		writeError(w, http.StatusMethodNotAllowed, "use "+method+" for "+r.URL.Path)
+		return
+	}
+
+	var req GameRequest
+	if method == http.MethodPost && !readRequest(w, r, &req) {
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.lastUsed = time.Now()
+	g := &s.Game
+
+	switch action {
+	case "":
+		writeJSON(w, http.StatusOK, s.state(""))
+
+	case "score":
+		writeJSON(w, http.StatusOK, g.Breakdown(scoreRules))
+
+	case "update":
+		op := Move
+		switch req.Op {
+		case "", "move":
+		case "remove":
+			op = Remove
+		default:
+			writeError(w, http.StatusBadRequest, "invalid op "+req.Op)
+			return
+		}
+
+		x, y := req.X+1, req.Y+1 // skip the border
+		if _, _, dir := g.Peek(x, y); dir == InvalidDir || dir == Empty {
+			writeJSON(w, http.StatusOK, s.state(updateNames[Invalid])) // not an arrow
+			return
+		}
+
+		_, _, res := g.Update(x, y, op)
+
+		if g.Count == 0 {
+			g.Completed = true
+		}
+
+		writeJSON(w, http.StatusOK, s.state(updateNames[res]))
+
+	case "shuffle":
+		mode, ok := shuffleModes[req.Dir]
+		if req.Dir == "" {
+			mode, ok = shuffleMode, true
+		}
+
+		if !ok {
+			writeError(w, http.StatusBadRequest, "invalid dir "+req.Dir)
+			return
+		}
+
+		if !g.Completed {
+			g.Shuffle(mode)
+		}
+
+		writeJSON(w, http.StatusOK, s.state(""))
+
+	case "undo":
+		if g.Completed {
+			writeError(w, http.StatusConflict, "the game is completed")
+			return
+		}
+
+		if _, _, ok := g.Undo(); !ok {
+			writeError(w, http.StatusConflict, "nothing to undo")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, s.state(""))
+	}
+}
+
+// decode the request body (an empty body is a valid request)
+func readRequest(w http.ResponseWriter, r *http.Request, req interface{}) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096))
+	if err := dec.Decode(req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return false
+	}
+
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false) // keep the arrows in the grid readable
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
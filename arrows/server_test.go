@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// send a request to the API, check the status and decode the response
+func apiCall(t *testing.T, ts *httptest.Server, method, path, body string, status int, resp interface{}) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, ts.URL+path, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != status {
+		t.Fatalf("%v %v: status %v, want %v", method, path, res.StatusCode, status)
+	}
+
+	if resp != nil {
+		if err := json.NewDecoder(res.Body).Decode(resp); err != nil {
+			t.Fatalf("%v %v: %v", method, path, err)
+		}
+	}
+}
+
+func TestServerAPI(t *testing.T) {
+	ts := httptest.NewServer(newServer().Handler())
+	defer ts.Close()
+
+	var st GameState
+
+	apiCall(t, ts, "POST", "/games", `{"width":6,"height":4,"seed":5}`, http.StatusCreated, &st)
+	if st.Width != 6 || st.Height != 4 || st.Seed != 5 || len(st.Grid) != 4 || len(st.Grid[0]) != 6 {
+		t.Fatalf("new game: %+v", st)
+	}
+
+	game := "/games/" + st.ID
+
+	apiCall(t, ts, "GET", game, "", http.StatusOK, &st)
+	if st.Moves != 0 || st.Completed {
+		t.Errorf("state: %+v", st)
+	}
+
+	// errors
+	apiCall(t, ts, "POST", "/games", `{"width":500}`, http.StatusBadRequest, nil)
+	apiCall(t, ts, "POST", "/games", `{"width":`, http.StatusBadRequest, nil)
+	apiCall(t, ts, "GET", "/games", "", http.StatusMethodNotAllowed, nil)
+	apiCall(t, ts, "GET", "/games/nosuchgame", "", http.StatusNotFound, nil)
+	apiCall(t, ts, "GET", game+"/nosuchaction", "", http.StatusNotFound, nil)
+	apiCall(t, ts, "GET", game+"/update", "", http.StatusMethodNotAllowed, nil)
+	apiCall(t, ts, "POST", game+"/update", `{"x":0,"y":0,"op":"jump"}`, http.StatusBadRequest, nil)
+	apiCall(t, ts, "POST", game+"/shuffle", `{"dir":"sideways"}`, http.StatusBadRequest, nil)
+	apiCall(t, ts, "POST", game+"/undo", "", http.StatusConflict, nil)
+
+	apiCall(t, ts, "POST", game+"/update", `{"x":-1,"y":0}`, http.StatusOK, &st)
+	if st.Result != "invalid" {
+		t.Errorf("update outside of the board: %q", st.Result)
+	}
+
+	// play until the board is clear (or give up)
+	for i := 0; i < 100 && !st.Completed; i++ {
+		progress := false
+
+		for y, row := range st.Grid {
+			for x, c := range row {
+				if c == '.' {
+					continue
+				}
+
+				var res GameState
+				apiCall(t, ts, "POST", game+"/update", fmtJSON(map[string]int{"x": x, "y": y}), http.StatusOK, &res)
+
+				if res.Result == "remove" || res.Result == "move" {
+					progress = true
+				}
+			}
+		}
+
+		if !progress {
+			apiCall(t, ts, "POST", game+"/shuffle", `{"dir":"random"}`, http.StatusOK, nil)
+		}
+
+		apiCall(t, ts, "GET", game, "", http.StatusOK, &st)
+	}
+
+	if !st.Completed || st.Remain != 0 || st.Moves == 0 {
+		t.Fatalf("game not completed: %+v", st)
+	}
+
+	// a completed game cannot be undone
+	apiCall(t, ts, "POST", game+"/undo", "", http.StatusConflict, nil)
+	apiCall(t, ts, "GET", game, "", http.StatusOK, &st)
+	if !st.Completed || st.Remain != 0 {
+		t.Errorf("completed game changed: %+v", st)
+	}
+
+	var b ScoreBreakdown
+	apiCall(t, ts, "GET", game+"/score", "", http.StatusOK, &b)
+	if b.Total != st.Score {
+		t.Errorf("score breakdown total=%v, state score=%v", b.Total, st.Score)
+	}
+
+	apiCall(t, ts, "DELETE", game, "", http.StatusNoContent, nil)
+	apiCall(t, ts, "GET", game, "", http.StatusNotFound, nil)
+}
+
+func TestServerUndo(t *testing.T) {
+	ts := httptest.NewServer(newServer().Handler())
+	defer ts.Close()
+
+	var st, moved GameState
+
+	apiCall(t, ts, "POST", "/games", `{"width":6,"height":4,"seed":5}`, http.StatusCreated, &st)
+	game := "/games/" + st.ID
+
+	// move the first arrow that can move, and undo it
+	for y, row := range st.Grid {
+		for x, c := range row {
+			if c == '.' || moved.Moves > 0 {
+				continue
+			}
+
+			apiCall(t, ts, "POST", game+"/update", fmtJSON(map[string]int{"x": x, "y": y}), http.StatusOK, &moved)
+		}
+	}
+
+	if moved.Moves == 0 {
+		t.Fatal("no arrow could move")
+	}
+
+	var undone GameState
+	apiCall(t, ts, "POST", game+"/undo", "", http.StatusOK, &undone)
+
+	undone.Result, st.Result = "", ""
+	if undone.Moves != 0 || undone.Remain != st.Remain || len(undone.Grid) != len(st.Grid) {
+		t.Errorf("undo: %+v, want %+v", undone, st)
+	}
+
+	for i := range st.Grid {
+		if undone.Grid[i] != st.Grid[i] {
+			t.Errorf("undo: row %v is %q, want %q", i, undone.Grid[i], st.Grid[i])
+		}
+	}
+
+	var shuffled GameState
+	apiCall(t, ts, "POST", game+"/shuffle", `{"dir":"flip"}`, http.StatusOK, &shuffled)
+	if shuffled.Shuffles != 1 {
+		t.Errorf("shuffles=%v, want 1", shuffled.Shuffles)
+	}
+}
+
+func fmtJSON(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// maximum number of shuffles before giving up on a board
+const maxShuffles = 1000
+
+// statistics for a simulated board
+type SimResult struct {
+	Board    int   `json:"board"`
+	Seed     int64 `json:"seed"`
+	Arrows   int   `json:"arrows"`
+	Free     int   `json:"free"`     // free arrows at start
+	Solvable bool  `json:"solvable"` // solved without shuffling
+	Solved   bool  `json:"solved"`
+	Shuffles int   `json:"shuffles"`
+	Turns    int   `json:"turns"`
+	Moves    int   `json:"moves"`
+	MaxSeq   int   `json:"maxseq"`
+	Score    int   `json:"score"`
+}
+
+// statistics for all simulated boards
+type SimSummary struct {
+	Boards      int     `json:"boards"`
+	Width       int     `json:"width"`
+	Height      int     `json:"height"`
+	Solvable    int     `json:"solvable"`
+	Unsolved    int     `json:"unsolved"`
+	AvgFree     float64 `json:"avg_free"`
+	AvgShuffles float64 `json:"avg_shuffles"`
+	ScoreMin    int     `json:"score_min"`
+	ScoreMax    int     `json:"score_max"`
+	ScoreAvg    float64 `json:"score_avg"`
+	ScoreMedian int     `json:"score_median"`
+}
+
+// autoplay a game until the board is cleared,
+// shuffling (with the specified mode) only when there are no free arrows left
+// (giving up after limit shuffles)
+func autoplay(g *Game, mode ShuffleMode, limit int) (turns, shuffles int, solved bool) {
+	for g.Count > 0 {
+		if g.PlayTurn() > None {
+			turns++
+			g.Seq = 0
+			continue
+		}
+
+		if shuffles == limit {
+			return
+		}
+
+		g.Shuffle(mode)
+		shuffles++
+	}
+
+	return turns, shuffles, true
+}
+
+func simulateBoard(board int, seed int64) SimResult {
+	var g Game
+
+	g.SetupSeed(gameWidth, gameHeight, 1, 1, seed)
+
+	res := SimResult{Board: board, Seed: g.Seed, Arrows: g.Count, Free: g.Free()}
+	res.Turns, res.Shuffles, res.Solved = autoplay(&g, shuffleMode, maxShuffles)
+	res.Solvable = res.Solved && res.Shuffles == 0
+	res.Moves = g.Moves
+	res.MaxSeq = g.MaxSeq
+	res.Score = g.ComputeScore()
+	return res
+}
+
+func summarize(results []SimResult) (sum SimSummary) {
+	sum.Boards = len(results)
+	sum.Width = gameWidth - 2
+	sum.Height = gameHeight - 2
+
+	if len(results) == 0 {
+		return
+	}
+
+	var scores []int
+
+	for _, r := range results {
+		if r.Solvable {
+			sum.Solvable++
+		}
+		if !r.Solved {
+			sum.Unsolved++
+		}
+
+		sum.AvgFree += float64(r.Free)
+		sum.AvgShuffles += float64(r.Shuffles)
+		sum.ScoreAvg += float64(r.Score)
+		scores = append(scores, r.Score)
+	}
+
+	n := float64(len(results))
+	sum.AvgFree /= n
+	sum.AvgShuffles /= n
+	sum.ScoreAvg /= n
+
+	sort.Ints(scores)
+	sum.ScoreMin = scores[0]
+	sum.ScoreMax = scores[len(scores)-1]
+	sum.ScoreMedian = scores[len(scores)/2]
+	return
+}
+
+// generate and autoplay n boards, without UI,
+// and write the results as "csv" or "json"
+func simulateGames(n int, format string, w io.Writer) error {
+	results := make([]SimResult, 0, n)
+
+	// the board seeds are generated from the game seed, so that a run can be repeated
+	seed := gameSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+
+	for i := 0; i < n; i++ {
+		results = append(results, simulateBoard(i+1, rng.Int63()))
+	}
+
+	sum := summarize(results)
+
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			Summary SimSummary  `json:"summary"`
+			Results []SimResult `json:"results"`
+		}{sum, results})
+
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"board", "seed", "arrows", "free", "solvable", "solved", "shuffles", "turns", "moves", "maxseq", "score"})
+
+		for _, r := range results {
+			cw.Write([]string{
+				strconv.Itoa(r.Board),
+				strconv.FormatInt(r.Seed, 10),
+				strconv.Itoa(r.Arrows),
+				strconv.Itoa(r.Free),
+				strconv.FormatBool(r.Solvable),
+				strconv.FormatBool(r.Solved),
+				strconv.Itoa(r.Shuffles),
+				strconv.Itoa(r.Turns),
+				strconv.Itoa(r.Moves),
+				strconv.Itoa(r.MaxSeq),
+				strconv.Itoa(r.Score),
+			})
+		}
+
+		cw.Flush()
+
+		// the summary goes to stderr, so that stdout is a valid csv file
+		fmt.Fprintf(os.Stderr, "boards=%v solvable=%v unsolved=%v avg-free=%.1f avg-shuffles=%.2f score min/median/avg/max=%v/%v/%.1f/%v\n",
+			sum.Boards, sum.Solvable, sum.Unsolved, sum.AvgFree, sum.AvgShuffles,
+			sum.ScoreMin, sum.ScoreMedian, sum.ScoreAvg, sum.ScoreMax)
+		return cw.Error()
+	}
+
+	return fmt.Errorf("invalid format %q", format)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// lifetime statistics for a board size
+type StatInfo struct {
+	Started   int
+	Completed int
+	Removed   int
+	MaxSeq    int
+	Moves     int // moves in completed games
+	Shuffles  int
+	Autoplay  int // games completed with autoplay
+	Manual    int // games completed without autoplay
+}
+
+// lifetime statistics, by board size
+type Stats map[string]*StatInfo
+
+var stats = Stats{}
+
+func statsKey(w, h int) string {
+	return strconv.Itoa(w*1000 + h)
+}
+
+func (s Stats) get(g *Game) *StatInfo {
+	key := statsKey(g.Width, g.Height)
+
+	si := s[key]
+	if si == nil {
+		si = &StatInfo{}
+		s[key] = si
+	}
+
+	return si
+}
+
+// a new game was started
+func (s Stats) Start(g *Game) {
+	s.get(g).Started++
+}
+
+// the game was completed
+func (s Stats) Complete(g *Game) {
+	si := s.get(g)
+	si.Completed++
+	si.Moves += g.Moves
+
+	if g.Autoplay {
+		si.Autoplay++
+	} else {
+		si.Manual++
+	}
+
+	s.add(si, g)
+}
+
+// the game was abandoned (reset or quit before completing it, or over with arrows left)
+func (s Stats) Abandon(g *Game) {
+	if g.Width == 0 || g.Completed || g.Scored {
+		return
+	}
+
+	s.add(s.get(g), g)
+}
+
+// add the arrows removed and the shuffles not counted yet (a resumed game was counted when it was abandoned)
+func (s Stats) add(si *StatInfo, g *Game) {
+	si.Removed += g.Removed - g.CountedRemoved
+	si.Shuffles += g.Shuffles - g.CountedShuffles
+	g.CountedRemoved, g.CountedShuffles = g.Removed, g.Shuffles
+
+	if g.MaxSeq > si.MaxSeq {
+		si.MaxSeq = g.MaxSeq
+	}
+}
+
+func (si StatInfo) AbandonRate() float64 {
+	if si.Started == 0 {
+		return 0
+	}
+
+	return float64(si.Started-si.Completed) / float64(si.Started)
+}
+
+func (si StatInfo) AvgMoves() float64 {
+	if si.Completed == 0 {
+		return 0
+	}
+
+	return float64(si.Moves) / float64(si.Completed)
+}
+
+// return the statistics for a board size, one line per item
+func (s Stats) Lines(width, height int) []string {
+	si := s[statsKey(width, height)]
+	if si == nil {
+		si = &StatInfo{}
+	}
+
+	return []string{
+		fmt.Sprintf("board size:      %vx%v", width-2, height-2),
+		fmt.Sprintf("games started:   %v", si.Started),
+		fmt.Sprintf("games completed: %v (autoplay=%v manual=%v)", si.Completed, si.Autoplay, si.Manual),
+		fmt.Sprintf("abandon rate:    %.0f%%", si.AbandonRate()*100),
+		fmt.Sprintf("arrows removed:  %v", si.Removed),
+		fmt.Sprintf("longest seq:     %v", si.MaxSeq),
+		fmt.Sprintf("average moves:   %.1f", si.AvgMoves()),
+		fmt.Sprintf("shuffles used:   %v", si.Shuffles),
+	}
+}
+
+// return the board sizes with statistics (width and height, including the border)
+func (s Stats) Sizes() (sizes [][2]int) {
+	for key := range s {
+		if k, err := strconv.Atoi(key); err == nil {
+			sizes = append(sizes, [2]int{k / 1000, k % 1000})
+		}
+	}
+
+	sort.Slice(sizes, func(i, j int) bool {
+		if sizes[i][0] == sizes[j][0] {
+			return sizes[i][1] < sizes[j][1]
+		}
+
+		return sizes[i][0] < sizes[j][0]
+	})
+
+	return
+}
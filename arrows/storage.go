@@ -0,0 +1,106 @@
+package main
+
+import (
+	"os"
+	"sync"
+)
+
+// Storage persists named objects (the score file, the saved game).
+//
+// There is a filesystem backend, a browser localStorage backend (for the js build)
+// and an in-memory backend.
+type Storage interface {
+	// return the content of the named object (an error matching os.ErrNotExist if it doesn't exist)
+	Load(name string) ([]byte, error)
+
+	// replace the content of the named object (atomically)
+	Store(name string, data []byte) error
+
+	// remove the named object
+	Delete(name string) error
+
+	// take an exclusive (advisory) lock on the named object
+	Lock(name string) (unlock func(), err error)
+
+	// keep a copy of the named object (i.e. because it cannot be decoded),
+	// and return the name of the copy
+	Backup(name string) (string, error)
+
+	// describe where the named object is stored
+	Location(name string) string
+}
+
+// names of the stored objects
+const (
+	scoresObject   = "scores"
+	savegameObject = "savegame"
+)
+
+// in-memory storage (nothing survives the process)
+type memStorage struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	locks   map[string]*sync.Mutex
+}
+
+func newMemStorage() *memStorage {
+	return &memStorage{objects: map[string][]byte{}, locks: map[string]*sync.Mutex{}}
+}
+
+func (m *memStorage) Load(name string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data, ok := m.objects[name]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+
+	return append([]byte(nil), data...), nil
+}
+
+func (m *memStorage) Store(name string, data []byte) error {
+	m.mu.Lock()
+	m.objects[name] = append([]byte(nil), data...)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *memStorage) Delete(name string) error {
+	m.mu.Lock()
+	delete(m.objects, name)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *memStorage) Lock(name string) (func(), error) {
+	m.mu.Lock()
+	l := m.locks[name]
+	if l == nil {
+		l = &sync.Mutex{}
+		m.locks[name] = l
+	}
+	m.mu.Unlock()
+
+	l.Lock()
+	return l.Unlock, nil
+}
+
+func (m *memStorage) Backup(name string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data, ok := m.objects[name]
+	if !ok {
+		return "", os.ErrNotExist
+	}
+
+	backup := name + ".bak"
+	m.objects[backup] = data
+	delete(m.objects, name)
+	return backup, nil
+}
+
+func (m *memStorage) Location(name string) string {
+	return "memory:" + name
+}
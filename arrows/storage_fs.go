@@ -0,0 +1,196 @@
+//go:build !js
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gioui.org/app"
+)
+
+const (
+	lockTimeout = 5 * time.Second
+	lockStale   = 30 * time.Second // a lock older than this was left by a crashed instance
+)
+
+// filesystem storage: one file per object
+type fileStorage struct {
+	path   func(name string) string
+	legacy func(name string) string // where the objects were stored before (nil if nowhere else)
+}
+
+// return the storage for the current platform:
+// $XDG_DATA_HOME/arrows/<name>.json if $XDG_DATA_HOME is set (reading the files in ${HOME} until they are saved again),
+// ${HOME}/.arrows (scores) and ${HOME}/.arrows-<name> otherwise,
+// or the application data directory if there is no home directory (mobile).
+func newStorage() Storage {
+	home := os.Getenv("HOME")
+
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		fs := &fileStorage{path: func(name string) string {
+			return filepath.Join(dir, "arrows", name+".json")
+		}}
+
+		if home != "" {
+			fs.legacy = homePath(home)
+		}
+
+		return fs
+	}
+
+	if home != "" {
+		return &fileStorage{path: homePath(home)}
+	}
+
+	if dir, err := app.DataDir(); err == nil {
+		return &fileStorage{path: func(name string) string {
+			return filepath.Join(dir, "arrows", name+".json")
+		}}
+	}
+
+	return newMemStorage()
+}
+
+// the files in the home directory
+func homePath(home string) func(name string) string {
+	return func(name string) string {
+		if name == scoresObject {
+			return filepath.Join(home, ".arrows")
+		}
+
+		return filepath.Join(home, ".arrows-"+name)
+	}
+}
+
+// return the path of an object, or the legacy path if it's only there
+func (fs *fileStorage) existing(name string) string {
+	path := fs.path(name)
+
+	if fs.legacy != nil {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			return fs.legacy(name)
+		}
+	}
+
+	return path
+}
+
+func (fs *fileStorage) Load(name string) ([]byte, error) {
+	return os.ReadFile(fs.existing(name))
+}
+
+// write a temporary file and rename it, so that a crash cannot leave a truncated file
+func (fs *fileStorage) Store(name string, data []byte) error {
+	path := fs.path(name)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// remove the object (also from the legacy location, so that it's not loaded from there)
+func (fs *fileStorage) Delete(name string) error {
+	if fs.legacy != nil {
+		if err := os.Remove(fs.legacy(name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	err := os.Remove(fs.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+// the lock is a file created next to the object file
+func (fs *fileStorage) Lock(name string) (func(), error) {
+	path := fs.path(name)
+	lock := path + ".lock"
+	start := time.Now()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	for {
+		f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			fmt.Fprintln(f, os.Getpid())
+			f.Close()
+			return func() { os.Remove(lock) }, nil
+		}
+
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+
+		if fi, err := os.Stat(lock); err == nil && time.Since(fi.ModTime()) > lockStale {
+			breakLock(lock, fi)
+			continue
+		}
+
+		if time.Since(start) > lockTimeout {
+			return nil, fmt.Errorf("cannot lock %v: timeout", path)
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// remove a stale lock. The lock is renamed first and removed only if it's still the stale one:
+// if another instance removed it and took the lock in the meantime, the new lock is put back
+// (the modification time is also compared, as the new lock file can reuse the same inode).
+func breakLock(lock string, stale os.FileInfo) {
+	tmp := fmt.Sprintf("%v.%v.stale", lock, os.Getpid())
+
+	if err := os.Rename(lock, tmp); err != nil {
+		return
+	}
+
+	if fi, err := os.Stat(tmp); err == nil && (!os.SameFile(fi, stale) || !fi.ModTime().Equal(stale.ModTime())) {
+		os.Rename(tmp, lock)
+		return
+	}
+
+	os.Remove(tmp)
+}
+
+func (fs *fileStorage) Backup(name string) (string, error) {
+	path := fs.existing(name)
+	backup := fmt.Sprintf("%v.%v.bak", path, time.Now().Format("20060102-150405"))
+	return backup, os.Rename(path, backup)
+}
+
+func (fs *fileStorage) Location(name string) string {
+	return fs.existing(name)
+}
@@ -0,0 +1,65 @@
+//go:build js
+
+package main
+
+import (
+	"os"
+	"syscall/js"
+)
+
+const storagePrefix = "arrows/"
+
+// browser storage: one localStorage item per object
+type localStorage struct {
+	ls js.Value
+}
+
+// return the storage for the browser (localStorage if available)
+func newStorage() Storage {
+	ls := js.Global().Get("localStorage")
+	if ls.IsUndefined() || ls.IsNull() {
+		return newMemStorage()
+	}
+
+	return &localStorage{ls: ls}
+}
+
+func (s *localStorage) Load(name string) ([]byte, error) {
+	v := s.ls.Call("getItem", storagePrefix+name)
+	if v.IsNull() || v.IsUndefined() {
+		return nil, os.ErrNotExist
+	}
+
+	return []byte(v.String()), nil
+}
+
+func (s *localStorage) Store(name string, data []byte) error {
+	s.ls.Call("setItem", storagePrefix+name, string(data))
+	return nil
+}
+
+func (s *localStorage) Delete(name string) error {
+	s.ls.Call("removeItem", storagePrefix+name)
+	return nil
+}
+
+// javascript is single threaded, and localStorage updates are atomic
+func (s *localStorage) Lock(name string) (func(), error) {
+	return func() {}, nil
+}
+
+func (s *localStorage) Backup(name string) (string, error) {
+	data, err := s.Load(name)
+	if err != nil {
+		return "", err
+	}
+
+	backup := name + ".bak"
+	s.Store(backup, data)
+	s.Delete(name)
+	return backup, nil
+}
+
+func (s *localStorage) Location(name string) string {
+	return "localStorage:" + storagePrefix + name
+}
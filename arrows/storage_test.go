@@ -0,0 +1,174 @@
+//go:build !js
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// file storage in a temporary directory, with the legacy files in a "home" directory
+func tempStorage(t *testing.T) (fs *fileStorage, home string) {
+	dir := t.TempDir()
+	home = filepath.Join(dir, "home")
+
+	fs = &fileStorage{
+		path:   func(name string) string { return filepath.Join(dir, "data", name+".json") },
+		legacy: homePath(home),
+	}
+
+	return
+}
+
+// check the basic operations of a storage backend
+func testStorage(t *testing.T, st Storage) {
+	if _, err := st.Load(savegameObject); !os.IsNotExist(err) {
+		t.Errorf("missing object: %v", err)
+	}
+
+	if err := st.Store(savegameObject, []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := st.Store(savegameObject, []byte("second")); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := st.Load(savegameObject); err != nil || string(data) != "second" {
+		t.Errorf("load: %q %v", data, err)
+	}
+
+	if err := st.Delete(savegameObject); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := st.Load(savegameObject); !os.IsNotExist(err) {
+		t.Errorf("deleted object: %v", err)
+	}
+
+	if err := st.Delete(savegameObject); err != nil {
+		t.Errorf("delete missing object: %v", err)
+	}
+
+	// the lock is exclusive
+	unlock, err := st.Lock(scoresObject)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	locked := make(chan struct{})
+
+	go func() {
+		if unlock, err := st.Lock(scoresObject); err == nil {
+			unlock()
+		}
+
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+		t.Error("lock taken twice")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	unlock()
+
+	select {
+	case <-locked:
+	case <-time.After(lockTimeout):
+		t.Error("lock not released")
+	}
+}
+
+func TestMemStorage(t *testing.T) {
+	testStorage(t, newMemStorage())
+}
+
+func TestFileStorage(t *testing.T) {
+	fs, _ := tempStorage(t)
+	testStorage(t, fs)
+}
+
+func TestFileStorageLegacy(t *testing.T) {
+	fs, home := tempStorage(t)
+
+	if err := os.MkdirAll(home, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(home, ".arrows"), []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the legacy file is read until the object is saved in the new location
+	if data, err := fs.Load(scoresObject); err != nil || string(data) != "old" {
+		t.Fatalf("load legacy: %q %v", data, err)
+	}
+
+	if err := fs.Store(scoresObject, []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := fs.Load(scoresObject); err != nil || string(data) != "new" {
+		t.Fatalf("load: %q %v", data, err)
+	}
+
+	// a deleted object is not loaded from the legacy location
+	if err := os.WriteFile(filepath.Join(home, ".arrows-savegame"), []byte("game"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fs.Delete(savegameObject); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fs.Load(savegameObject); !os.IsNotExist(err) {
+		t.Errorf("deleted object: %v", err)
+	}
+}
+
+func TestFileStorageStaleLock(t *testing.T) {
+	fs, _ := tempStorage(t)
+
+	unlock, err := fs.Lock(scoresObject)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a lock left by a crashed instance is removed
+	lock := fs.path(scoresObject) + ".lock"
+	old := time.Now().Add(-2 * lockStale)
+
+	if err := os.Chtimes(lock, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	unlock2, err := fs.Lock(scoresObject)
+	if err != nil {
+		t.Fatalf("stale lock not removed: %v", err)
+	}
+
+	unlock2()
+	unlock() // the stale lock is already gone
+
+	// a lock taken by another instance after the stale one was removed is put back
+	unlock, _ = fs.Lock(scoresObject)
+	defer unlock()
+
+	stale, err := os.Stat(lock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Remove(lock)
+	os.WriteFile(lock, []byte("other"), 0644)
+
+	breakLock(lock, stale)
+
+	if data, err := os.ReadFile(lock); err != nil || string(data) != "other" {
+		t.Errorf("new lock removed: %q %v", data, err)
+	}
+}
@@ -0,0 +1,135 @@
+//go:build !ios && !android && !js
+
+package main
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/generators"
+)
+
+// procedurally generated sound effects
+
+const (
+	baseFreq   = 440.0 // A4
+	maxSeqStep = 24    // the pitch stops rising after two octaves
+)
+
+// return the frequency n semitones above (or below) f
+func semitones(f float64, n int) float64 {
+	return f * math.Pow(2, float64(n)/12)
+}
+
+// a sine tone of frequency freq and duration d, with a short attack and an exponential decay
+func tone(sr beep.SampleRate, freq float64, d time.Duration, gain float64) beep.Streamer {
+	sin, err := generators.SinTone(sr, int(freq))
+	if err != nil {
+		return beep.Silence(sr.N(d))
+	}
+
+	n := sr.N(d)
+	attack := sr.N(5 * time.Millisecond)
+	pos := 0
+
+	return beep.Take(n, beep.StreamerFunc(func(samples [][2]float64) (int, bool) {
+		sn, ok := sin.Stream(samples)
+
+		for i := range samples[:sn] {
+			env := math.Exp(-4 * float64(pos) / float64(n))
+			if pos < attack {
+				env *= float64(pos) / float64(attack)
+			}
+
+			samples[i][0] *= gain * env
+			samples[i][1] *= gain * env
+			pos++
+		}
+
+		return sn, ok
+	}))
+}
+
+// a chord of tones, played together
+func chord(sr beep.SampleRate, d time.Duration, gain float64, freqs ...float64) beep.Streamer {
+	var tones []beep.Streamer
+
+	for _, f := range freqs {
+		tones = append(tones, tone(sr, f, d, gain/float64(len(freqs))))
+	}
+
+	return beep.Mix(tones...)
+}
+
+// a sequence of notes
+func arpeggio(sr beep.SampleRate, d time.Duration, gain float64, freqs ...float64) beep.Streamer {
+	var tones []beep.Streamer
+
+	for _, f := range freqs {
+		tones = append(tones, tone(sr, f, d, gain))
+	}
+
+	return beep.Seq(tones...)
+}
+
+// return the frequency of the remove sound for the current sequence,
+// rising one semitone for each arrow in the sequence
+func removeFreq(seq int) float64 {
+	if seq < 1 {
+		seq = 1
+	}
+
+	if seq > maxSeqStep {
+		seq = maxSeqStep
+	}
+
+	return semitones(baseFreq, seq-1)
+}
+
+// return the generated sound for the update (seq is the current sequence length)
+func synthSound(sr beep.SampleRate, mov Updates, seq int) beep.Streamer {
+	switch mov {
+	case Remove:
+		f := removeFreq(seq)
+
+		switch {
+		case seq >= 12: // major chord
+			return chord(sr, 150*time.Millisecond, 0.5, f, semitones(f, 4), semitones(f, 7))
+
+		case seq >= 6: // fifth
+			return chord(sr, 150*time.Millisecond, 0.5, f, semitones(f, 7))
+
+		default:
+			return tone(sr, f, 150*time.Millisecond, 0.5)
+		}
+
+	case Move:
+		return tone(sr, semitones(baseFreq, -5), 100*time.Millisecond, 0.4)
+
+	case None:
+		return chord(sr, 200*time.Millisecond, 0.5, 110, 116)
+
+	case Shuffle: // a random sequence of notes from the pentatonic scale
+		scale := []int{0, 2, 4, 7, 9, 12}
+
+		var freqs []float64
+		for i := 0; i < 5; i++ {
+			freqs = append(freqs, semitones(baseFreq/2, scale[rand.Intn(len(scale))]))
+		}
+
+		return arpeggio(sr, 50*time.Millisecond, 0.4, freqs...)
+
+	case Undo:
+		return arpeggio(sr, 80*time.Millisecond, 0.4, semitones(baseFreq, 7), baseFreq)
+
+	case Win:
+		c := semitones(baseFreq, 3) // C5
+		return beep.Seq(
+			arpeggio(sr, 120*time.Millisecond, 0.5, c, semitones(c, 4), semitones(c, 7)),
+			chord(sr, 600*time.Millisecond, 0.6, semitones(c, 12), semitones(c, 16), semitones(c, 19)))
+	}
+
+	return nil
+}
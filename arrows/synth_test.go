@@ -0,0 +1,114 @@
+//go:build !ios && !android && !js
+
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+const testRate = beep.SampleRate(44100)
+
+// render a stream into a buffer (left channel only)
+func renderStream(s beep.Streamer) (out []float64) {
+	buf := make([][2]float64, 512)
+
+	for {
+		n, ok := s.Stream(buf)
+		for _, sample := range buf[:n] {
+			out = append(out, sample[0])
+		}
+
+		if !ok || n == 0 {
+			return
+		}
+	}
+}
+
+// estimate the frequency of a tone, counting the zero crossings
+func pitch(samples []float64) float64 {
+	crossings := 0
+
+	for i := 1; i < len(samples); i++ {
+		if (samples[i-1] < 0) != (samples[i] < 0) {
+			crossings++
+		}
+	}
+
+	return float64(crossings) / 2 / (float64(len(samples)) / float64(testRate))
+}
+
+// check that f is within 2% of want
+func checkPitch(t *testing.T, what string, f, want float64) {
+	t.Helper()
+
+	if math.Abs(f-want)/want > 0.02 {
+		t.Errorf("%v: pitch %.1f, want %.1f", what, f, want)
+	}
+}
+
+func TestSynthRemovePitch(t *testing.T) {
+	prev := 0.0
+
+	// single tones, one semitone higher for each arrow in the sequence
+	for seq := 1; seq < 6; seq++ {
+		samples := renderStream(synthSound(testRate, Remove, seq))
+
+		if want := testRate.N(150 * time.Millisecond); len(samples) != want {
+			t.Errorf("seq=%v: %v samples, want %v", seq, len(samples), want)
+		}
+
+		f := pitch(samples)
+		checkPitch(t, "remove", f, semitones(baseFreq, seq-1))
+
+		if prev > 0 {
+			checkPitch(t, "semitone step", f/prev, math.Pow(2, 1.0/12))
+		}
+
+		prev = f
+	}
+}
+
+func TestSynthRemoveFreq(t *testing.T) {
+	if removeFreq(0) != baseFreq || removeFreq(1) != baseFreq {
+		t.Errorf("first arrow: %v, want %v", removeFreq(1), baseFreq)
+	}
+
+	if f := removeFreq(13); math.Abs(f-2*baseFreq) > 1e-9 {
+		t.Errorf("octave: %v, want %v", f, 2*baseFreq)
+	}
+
+	// the pitch stops rising after maxSeqStep
+	if removeFreq(maxSeqStep+10) != removeFreq(maxSeqStep) {
+		t.Errorf("pitch rising after %v", maxSeqStep)
+	}
+}
+
+func TestSynthUndo(t *testing.T) {
+	// two notes, going down a fifth
+	samples := renderStream(synthSound(testRate, Undo, 0))
+	half := len(samples) / 2
+
+	checkPitch(t, "first note", pitch(samples[:half]), semitones(baseFreq, 7))
+	checkPitch(t, "second note", pitch(samples[half:]), baseFreq)
+}
+
+func TestSynthSounds(t *testing.T) {
+	for _, mov := range []Updates{Remove, Move, None, Shuffle, Undo, Win} {
+		samples := renderStream(synthSound(testRate, mov, 15))
+		if len(samples) == 0 {
+			t.Errorf("%v: no sound", mov)
+			continue
+		}
+
+		for i, s := range samples {
+			if math.IsNaN(s) || math.Abs(s) > 1 {
+				t.Errorf("%v: sample %v out of range: %v", mov, i, s)
+				break
+			}
+		}
+	}
+}
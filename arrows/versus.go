@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// hot-seat versus mode: players alternate turns on the same board.
+//
+// A player keeps playing as long as arrows are removed,
+// the turn passes to the next player on a move or a blocked arrow.
+type Versus struct {
+	Players int
+	Turn    int
+	Scores  []int
+
+	turnScore int // game score at the start of the turn
+	turnStack int // undo stack size at the start of the turn
+}
+
+var versus *Versus
+
+func newVersus(players int) *Versus {
+	return &Versus{Players: players, Scores: make([]int, players)}
+}
+
+// reset scores and turn (for a new game)
+func (v *Versus) Reset() {
+	if v == nil {
+		return
+	}
+
+	v.Turn = 0
+	v.Scores = make([]int, v.Players)
+	v.turnScore = 0
+	v.turnStack = 0
+}
+
+// update the current player score after an action on the cell at cx,cy (game coordinates)
+// and pass the turn if the player moved an arrow or clicked on a blocked one
+// (a click on an empty cell doesn't count)
+func (v *Versus) Play(g *Game, cx, cy int, res Updates) {
+	if v == nil || res == Invalid {
+		return
+	}
+
+	v.Scores[v.Turn] += g.Score - v.turnScore
+	v.turnScore = g.Score
+
+	if res == Move || (res == None && g.Screen[cy][cx] != Empty) {
+		v.Turn = (v.Turn + 1) % v.Players
+		g.Seq = 0 // the sequence bonus is per turn
+		v.turnStack = g.StackSize()
+	}
+}
+
+// players can only undo their own moves
+func (v *Versus) CanUndo(g *Game) bool {
+	return v == nil || g.StackSize() > v.turnStack
+}
+
+// update the current player score after an undo
+func (v *Versus) Undo(g *Game) {
+	if v == nil {
+		return
+	}
+
+	v.Scores[v.Turn] += g.Score - v.turnScore
+	v.turnScore = g.Score
+}
+
+// shuffling clears the undo stack and the current sequence
+func (v *Versus) Shuffle(g *Game) {
+	if v == nil {
+		return
+	}
+
+	v.turnScore = g.Score
+	v.turnStack = g.StackSize()
+}
+
+// return the players scores, and whose turn it is
+func (v *Versus) Status() string {
+	if v == nil {
+		return ""
+	}
+
+	var parts []string
+
+	for i, s := range v.Scores {
+		p := fmt.Sprintf("P%v=%v", i+1, s)
+		if i == v.Turn {
+			p = "[" + p + "]"
+		}
+
+		parts = append(parts, p)
+	}
+
+	return fmt.Sprintf("player %v to play: %v", v.Turn+1, strings.Join(parts, " "))
+}
+
+// return the final result
+func (v *Versus) Result() string {
+	if v == nil {
+		return ""
+	}
+
+	best := 0
+	tie := false
+
+	for i, s := range v.Scores {
+		if s > v.Scores[best] {
+			best, tie = i, false
+		} else if i != best && s == v.Scores[best] {
+			tie = true
+		}
+	}
+
+	var parts []string
+	for i, s := range v.Scores {
+		parts = append(parts, fmt.Sprintf("P%v=%v", i+1, s))
+	}
+
+	if tie {
+		return "It's a tie: " + strings.Join(parts, " ")
+	}
+
+	return fmt.Sprintf("Player %v wins: %v", best+1, strings.Join(parts, " "))
+}
@@ -0,0 +1,69 @@
+package main
+
+import "testing"
+
+// a game with the board described by rows of gridChars (without the border)
+func gridGame(rows ...string) *Game {
+	chars := map[byte]Dir{}
+	for d, c := range gridChars {
+		chars[c] = d
+	}
+
+	g := &Game{Width: len(rows[0]) + 2, Height: len(rows) + 2, cellwidth: 1, cellheight: 1}
+	g.Screen = append(g.Screen, make([]Dir, g.Width))
+
+	for _, r := range rows {
+		row := make([]Dir, g.Width)
+
+		for x := 0; x < len(r); x++ {
+			if row[x+1] = chars[r[x]]; row[x+1] != Empty {
+				g.Count++
+			}
+		}
+
+		g.Screen = append(g.Screen, row)
+	}
+
+	g.Screen = append(g.Screen, make([]Dir, g.Width))
+	return g
+}
+
+func TestVersusTurn(t *testing.T) {
+	// x=1 is free, 2 and 3 are blocked, 4 is empty, 5 can only move
+	g := gridGame("^><.>.<")
+	v := newVersus(2)
+
+	play := func(what string, x int, res Updates, turn int) {
+		t.Helper()
+
+		cx, cy, mov := g.Update(x, 1, Move)
+		if mov != res {
+			t.Fatalf("%v at %v: %v, want %v", what, x, mov, res)
+		}
+
+		v.Play(g, cx, cy, mov)
+		if v.Turn != turn {
+			t.Errorf("%v: turn %v, want %v", what, v.Turn, turn)
+		}
+	}
+
+	// removing an arrow keeps the turn
+	play("remove", 1, Remove, 0)
+
+	// and so does a click on an empty cell
+	play("empty cell", 4, None, 0)
+	play("removed arrow", 1, None, 0)
+
+	if v.Scores[0] != g.Score || v.Scores[0] == 0 || v.Scores[1] != 0 {
+		t.Errorf("scores %v, game score %v", v.Scores, g.Score)
+	}
+
+	// a click on a blocked arrow passes the turn
+	play("blocked arrow", 2, None, 1)
+
+	// and so does a move
+	play("move", 5, Move, 0)
+
+	// clicks outside the board are ignored
+	play("border", 0, Invalid, 0)
+}